@@ -0,0 +1,425 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// apiDef
+// an API definition file lists the operations of the API, and the messages
+// they carry; the paths are generated from it, e.g.
+//
+//	operations:
+//	  - method: post
+//	    path: /payments
+//	    request: pacs.008.001.08
+//	    responses:
+//	      "200": {description: Accepted, message: pacs.002.001.10}
+//	      "400": {description: Rejected, message: error}
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// the message name for the generic error body
+const errorMessage = "error"
+
+// the API definition file
+type apiDefinition struct {
+	Title      string                  `yaml:"title"`
+	Version    string                  `yaml:"version"`
+	Servers    []string                `yaml:"servers"`
+	Operations []apiOperation          `yaml:"operations"`
+	Webhooks   map[string]apiOperation `yaml:"webhooks"` // OAS 3.1 only
+
+	// shared by every operation, see apiShared
+	SecuritySchemes yaml.Node        `yaml:"securitySchemes"` // copied as it is
+	Security        []apiRequirement `yaml:"security"`
+	Parameters      []apiHeader      `yaml:"parameters"`
+	ResponseHeaders []apiHeader      `yaml:"responseHeaders"`
+
+	AsyncAPI *asyncDefinition `yaml:"asyncapi"` // for -asyncapi
+}
+
+// an operation, or a callback operation
+type apiOperation struct {
+	Method      string                  `yaml:"method"`
+	Path        string                  `yaml:"path"`
+	URL         string                  `yaml:"url"` // callbacks only: the runtime expression
+	OperationID string                  `yaml:"operationId"`
+	Summary     string                  `yaml:"summary"`
+	Description string                  `yaml:"description"`
+	Tags        []string                `yaml:"tags"`
+	Request     string                  `yaml:"request"` // a message or schema name
+	Parameters  []apiHeader             `yaml:"parameters"`
+	Headers     []apiHeader             `yaml:"headers"`
+	Responses   map[string]apiResponse  `yaml:"responses"`
+	Callbacks   map[string]apiOperation `yaml:"callbacks"`
+	Security    *[]apiRequirement       `yaml:"security"` // overrides the global security, if given
+}
+
+// a response for one status code
+type apiResponse struct {
+	Description string      `yaml:"description"`
+	Message     string      `yaml:"message"` // a message or schema name
+	Headers     []apiHeader `yaml:"headers"`
+}
+
+// a request or response header, or a parameter
+type apiHeader struct {
+	Name        string    `yaml:"name"`
+	In          string    `yaml:"in"` // parameters only: path, query or header (the default)
+	Description string    `yaml:"description"`
+	Required    bool      `yaml:"required"`
+	Schema      yaml.Node `yaml:"schema"` // copied as it is; a string if omitted
+}
+
+// the HTTP methods that OAS allows
+var apiMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var regPathParam = regexp.MustCompile(`\{([^{}]*)\}`) // a {name} in a path
+
+// parse and check an API definition
+func parseApiDef(text []byte) (*apiDefinition, error) {
+	api := &apiDefinition{}
+	dec := yaml.NewDecoder(strings.NewReader(string(text)))
+	dec.KnownFields(true)
+	if err := dec.Decode(api); err != nil {
+		return nil, err
+	}
+	if err := checkShared(api); err != nil {
+		return nil, err
+	}
+	if api.AsyncAPI != nil {
+		if err := checkAsync(api.AsyncAPI); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+	for _, op := range api.Operations {
+		if !strings.HasPrefix(op.Path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", op.Path)
+		}
+		if err := checkOperation(op, api); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", op.Method, op.Path, err)
+		}
+		key := op.Method + " " + op.Path
+		if seen[key] {
+			return nil, fmt.Errorf("%s is defined twice", key)
+		}
+		seen[key] = true
+	}
+	for name, hook := range api.Webhooks {
+		if err := checkOperation(hook, api); err != nil {
+			return nil, fmt.Errorf("webhook %s: %v", name, err)
+		}
+	}
+	return api, nil
+}
+
+// check the method, parameters and security of an operation and its callbacks
+func checkOperation(op apiOperation, api *apiDefinition) error {
+	found := false
+	for _, m := range apiMethods {
+		found = found || m == op.Method
+	}
+	if !found {
+		return fmt.Errorf("method %q must be one of %s", op.Method, strings.Join(apiMethods, ", "))
+	}
+	if err := checkHeaders("parameters", op.Parameters, "path", "query", "header"); err != nil {
+		return err
+	}
+	if err := checkHeaders("headers", op.Headers, "header"); err != nil {
+		return err
+	}
+	for status, resp := range op.Responses {
+		if err := checkHeaders(status+" headers", resp.Headers, "header"); err != nil {
+			return err
+		}
+	}
+	// a callback's path is its url; a webhook has none
+	path := op.Path
+	if op.URL != "" {
+		path = op.URL
+	}
+	if err := checkPathParameters(path, op.Parameters); err != nil {
+		return err
+	}
+	if op.Security != nil {
+		if err := checkSecurity(*op.Security, api); err != nil {
+			return err
+		}
+	}
+	for name, cb := range op.Callbacks {
+		if cb.URL == "" {
+			return fmt.Errorf("callback %s has no url", name)
+		}
+		if err := checkOperation(cb, api); err != nil {
+			return fmt.Errorf("callback %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// check that each {name} in a path is a required path parameter, and each
+// path parameter is in the path
+// {$request...} etc. are runtime expressions, in callback urls
+func checkPathParameters(path string, params []apiHeader) error {
+	names := make(map[string]bool)
+	for _, m := range regPathParam.FindAllStringSubmatch(path, -1) {
+		if !strings.HasPrefix(m[1], "$") {
+			names[m[1]] = true
+		}
+	}
+	for _, p := range params {
+		if p.In != "path" {
+			continue
+		}
+		if !names[p.Name] {
+			return fmt.Errorf("path parameter %s is not in the path", p.Name)
+		}
+		if !p.Required {
+			return fmt.Errorf("path parameter %s must be required", p.Name)
+		}
+		delete(names, p.Name)
+	}
+	missing := make([]string, 0, len(names))
+	for name := range names {
+		missing = append(missing, "{"+name+"}")
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s has no path parameter", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// build the document headers from the API definition
+func apiHeaders(title string, servers []string, ctxt *context) *docMap {
+	var doc *docMap
+	if ctxt.oasVersion == oas20 {
+		doc = swaggerInfo(title, docVersion(ctxt), servers, ctxt)
+	} else {
+		doc = openapiInfo(title, docVersion(ctxt), servers, ctxt)
+	}
+	paths := doc.child("paths")
+	for _, op := range ctxt.api.Operations {
+		apiOperationDoc(paths.child(op.Path).child(op.Method), op, ctxt)
+	}
+	return doc
+}
+
+// build an operation
+func apiOperationDoc(m *docMap, op apiOperation, ctxt *context) {
+	if op.OperationID != "" {
+		m.set("operationId", op.OperationID)
+	}
+	if op.Summary != "" {
+		m.set("summary", op.Summary)
+	}
+	if op.Description != "" {
+		m.set("description", op.Description)
+	}
+	if len(op.Tags) > 0 {
+		m.set("tags", flowStrings(op.Tags))
+	}
+	if op.Security != nil {
+		if l := securityList(*op.Security, op.Method+" "+op.Path, ctxt); l != nil {
+			m.set("security", l)
+		}
+	}
+
+	params := sharedParameters(ctxt)
+	for _, h := range op.Parameters {
+		params = append(params, apiParameter(h, ctxt))
+	}
+	for _, h := range op.Headers {
+		params = append(params, apiParameter(h, ctxt))
+	}
+	if op.Request != "" && ctxt.oasVersion == oas20 {
+		params = append(params, newDocMap().
+			set("in", "body").
+			set("name", "body").
+			set("required", true).
+			set("schema", apiSchema(op.Request, ctxt)))
+	}
+	if len(params) > 0 {
+		m.set("parameters", params)
+	}
+	if op.Request != "" && ctxt.oasVersion != oas20 {
+		body := m.child("requestBody")
+		body.set("required", true)
+		apiContent(body, op.Request, ctxt)
+	}
+
+	responses := m.child("responses")
+	statuses := make([]string, 0, len(op.Responses))
+	for status := range op.Responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		resp := op.Responses[status]
+		r := responses.child(status)
+		r.set("description", responseDescription(status, resp))
+		if len(resp.Headers) > 0 {
+			headers := r.child("headers")
+			for _, h := range resp.Headers {
+				headers.set(h.Name, responseHeader(h, ctxt))
+			}
+		}
+		if resp.Message != "" {
+			if ctxt.oasVersion == oas20 {
+				r.set("schema", apiSchema(resp.Message, ctxt))
+			} else {
+				apiContent(r, resp.Message, ctxt)
+			}
+		}
+	}
+	if responses.len() == 0 {
+		responses.child("200").set("description", "Happy path")
+	}
+	sharedResponseHeaders(responses, ctxt)
+
+	if len(op.Callbacks) > 0 {
+		if ctxt.oasVersion == oas20 {
+			fmt.Printf("Warning: callbacks of %s %s ignored, Swagger 2.0 has no callbacks\n", op.Method, op.Path)
+			return
+		}
+		callbacks := m.child("callbacks")
+		names := make([]string, 0, len(op.Callbacks))
+		for name := range op.Callbacks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cb := op.Callbacks[name]
+			apiOperationDoc(callbacks.child(name).child(cb.URL).child(cb.Method), cb, ctxt)
+		}
+	}
+}
+
+// the description of a response, which OAS requires
+func responseDescription(status string, resp apiResponse) string {
+	if resp.Description != "" {
+		return resp.Description
+	}
+	code := 0
+	fmt.Sscanf(status, "%d", &code)
+	if text := http.StatusText(code); text != "" {
+		return text
+	}
+	return "Response"
+}
+
+// a request parameter, a header unless it says otherwise
+func apiParameter(h apiHeader, ctxt *context) *docMap {
+	in := h.In
+	if in == "" {
+		in = "header"
+	}
+	p := newDocMap().set("name", h.Name).set("in", in)
+	if h.Description != "" {
+		p.set("description", h.Description)
+	}
+	if h.Required {
+		p.set("required", true)
+	}
+	headerSchema(p, h, ctxt)
+	return p
+}
+
+// a response header
+func responseHeader(h apiHeader, ctxt *context) *docMap {
+	m := newDocMap()
+	if h.Description != "" {
+		m.set("description", h.Description)
+	}
+	headerSchema(m, h, ctxt)
+	return m
+}
+
+// the schema of a header: as given, or a string
+// Swagger 2.0 headers have the type etc. directly, rather than a schema
+func headerSchema(m *docMap, h apiHeader, ctxt *context) {
+	schema := newDocMap().set("type", "string")
+	if h.Schema.Kind != 0 {
+		v, err := docFromYaml(&h.Schema)
+		s, ok := v.(*docMap)
+		if err != nil || !ok {
+			fmt.Printf("API definition: %s schema is not a mapping\n", h.Name)
+			os.Exit(2)
+		}
+		schema = s
+	}
+	if ctxt.oasVersion != oas20 {
+		m.set("schema", schema)
+		return
+	}
+	for _, k := range schema.keys {
+		m.set(k, schema.vals[k])
+	}
+}
+
+// the content of a request or response body carrying a message
+// the XML form is only known for the messages themselves
+func apiContent(m *docMap, name string, ctxt *context) {
+	content := m.child("content")
+	content.child("application/json").set("schema", apiSchema(name, ctxt))
+	if msg := findMessage(name, ctxt); msg != nil && ctxt.xml {
+		content.child("application/xml").set("schema", schemaRef(msg.root.etype, ctxt))
+	}
+}
+
+// the schema for a message or schema name
+func apiSchema(name string, ctxt *context) *docMap {
+	if name == errorMessage {
+		return errorSchema(ctxt)
+	}
+	if msg := findMessage(name, ctxt); msg != nil {
+		return schemaRef(messageRoot(msg, ctxt), ctxt)
+	}
+	if simple, ok := ctxt.simpleTypes[name]; ok && simple.include {
+		return schemaRef(name, ctxt)
+	}
+	if cmplx, ok := ctxt.complexTypes[name]; ok && cmplx.include {
+		return schemaRef(name, ctxt)
+	}
+	fmt.Printf("API definition: %s is not a message or an included type\n", name)
+	os.Exit(2)
+	return nil
+}
+
+// the identifier of a message: the XSD file name without .xsd
+func messageID(msg *message) string {
+	return strings.TrimSuffix(msg.inFileBase, filepath.Ext(msg.inFileBase))
+}
+
+// find a message by its identifier
+func findMessage(id string, ctxt *context) *message {
+	for _, msg := range ctxt.messages {
+		if messageID(msg) == id {
+			return msg
+		}
+	}
+	return nil
+}
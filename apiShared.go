@@ -0,0 +1,396 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// apiShared
+// the security and headers that every operation shares, from the
+// API definition file, e.g.
+//
+//	securitySchemes:
+//	  oauth2: {type: oauth2, flows: ...}
+//	security:
+//	  - oauth2: [payments]
+//	parameters:
+//	  - {name: Idempotency-Key, required: true}
+//	responseHeaders:
+//	  - {name: X-Correlation-ID}
+//
+// parameters and response headers are components, referenced from each
+// operation; Swagger 2.0 has no header components, so there the response
+// headers are written out in each response
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var regNonComponent = regexp.MustCompile("[^A-Za-z0-9._-]+") // characters not allowed in a component name
+
+// a security requirement: scheme names and their scopes
+type apiRequirement map[string][]string
+
+// check the shared sections of an API definition
+func checkShared(api *apiDefinition) error {
+	if api.SecuritySchemes.Kind != 0 && api.SecuritySchemes.Kind != yaml.MappingNode {
+		return fmt.Errorf("securitySchemes must be a mapping")
+	}
+	if err := checkSecurity(api.Security, api); err != nil {
+		return err
+	}
+	// a path parameter would have to be in every path
+	if err := checkHeaders("parameters", api.Parameters, "query", "header"); err != nil {
+		return err
+	}
+	return checkHeaders("responseHeaders", api.ResponseHeaders, "header")
+}
+
+// check that security requirements only use the schemes defined
+func checkSecurity(reqs []apiRequirement, api *apiDefinition) error {
+	schemes := make(map[string]bool)
+	for i := 0; i+1 < len(api.SecuritySchemes.Content); i += 2 {
+		schemes[api.SecuritySchemes.Content[i].Value] = true
+	}
+	for _, req := range reqs {
+		for name := range req {
+			if !schemes[name] {
+				return fmt.Errorf("security scheme %s is not defined", name)
+			}
+		}
+	}
+	return nil
+}
+
+// check that headers or parameters have names, each used once, and are in
+// one of the places given; a header is the default
+func checkHeaders(section string, headers []apiHeader, ins ...string) error {
+	seen := make(map[string]bool)
+	for _, h := range headers {
+		if h.Name == "" {
+			return fmt.Errorf("%s: a header has no name", section)
+		}
+		in := h.In
+		if in == "" {
+			in = "header"
+		}
+		found := false
+		for _, i := range ins {
+			found = found || i == in
+		}
+		if !found {
+			return fmt.Errorf("%s: %s is in %s, which must be %s", section, h.Name, in, strings.Join(ins, " or "))
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("%s: %s is defined twice", section, h.Name)
+		}
+		seen[h.Name] = true
+	}
+	return nil
+}
+
+// the global security, after the servers
+func globalSecurity(doc *docMap, ctxt *context) {
+	if ctxt.api != nil && len(ctxt.api.Security) > 0 {
+		if l := securityList(ctxt.api.Security, "the API", ctxt); l != nil {
+			doc.set("security", l)
+		}
+	}
+}
+
+// a list of security requirements, of the API or an operation (scope)
+// an empty list means no security, e.g. for a public operation
+// requirements using a scheme this version doesn't have are left out;
+// in Swagger 2.0 an oauth2 scheme with several flows is a definition for
+// each, so a requirement using it is one for each
+// if that leaves none, the list is nil rather than an empty one, which
+// would say there's no security
+func securityList(reqs []apiRequirement, scope string, ctxt *context) docList {
+	names := versionSchemeNames(ctxt)
+	l := make(docList, 0, len(reqs))
+	for _, req := range reqs {
+		alts := []*docMap{newDocMap()}
+		for _, name := range sortedRequirement(req) {
+			next := make([]*docMap, 0, len(alts))
+			for _, alt := range alts {
+				for _, n := range names[name] {
+					m := newDocMap()
+					for _, k := range alt.keys {
+						m.set(k, alt.vals[k])
+					}
+					next = append(next, m.set(n, flowStrings(req[name])))
+				}
+			}
+			alts = next
+		}
+		for _, m := range alts {
+			l = append(l, m)
+		}
+	}
+	if len(reqs) > 0 && len(l) == 0 {
+		consequence := "it is written without security"
+		if scope != "the API" {
+			consequence = "it falls back to the security of the API"
+		}
+		fmt.Printf("Warning: the security of %s only uses schemes %s hasn't, so %s\n", scope, oasName(ctxt), consequence)
+		return nil
+	}
+	return l
+}
+
+// the scheme names of a requirement, sorted
+func sortedRequirement(req apiRequirement) []string {
+	names := make([]string, 0, len(req))
+	for name := range req {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the component name for a header
+func componentName(header string) string {
+	return regNonComponent.ReplaceAllString(header, "_")
+}
+
+// references to the shared parameters, to start the parameters of an operation
+func sharedParameters(ctxt *context) docList {
+	params := make(docList, 0)
+	if ctxt.api == nil {
+		return params
+	}
+	prefix := "#/components/parameters/"
+	if ctxt.oasVersion == oas20 {
+		prefix = "#/parameters/"
+	}
+	for _, h := range ctxt.api.Parameters {
+		params = append(params, newDocMap().set("$ref", prefix+componentName(h.Name)))
+	}
+	return params
+}
+
+// add the shared headers to each response of an operation
+// a header the response already has is left as it is
+func sharedResponseHeaders(responses *docMap, ctxt *context) {
+	if ctxt.api == nil || len(ctxt.api.ResponseHeaders) == 0 {
+		return
+	}
+	for _, status := range responses.keys {
+		r, ok := responses.vals[status].(*docMap)
+		if !ok {
+			continue
+		}
+		headers := r.child("headers")
+		for _, h := range ctxt.api.ResponseHeaders {
+			if _, ok := headers.get(h.Name); ok {
+				continue
+			}
+			if ctxt.oasVersion == oas20 {
+				headers.set(h.Name, responseHeader(h, ctxt))
+			} else {
+				headers.set(h.Name, newDocMap().set("$ref", "#/components/headers/"+componentName(h.Name)))
+			}
+		}
+	}
+}
+
+// add the shared parameters, headers and security schemes to the components
+// in Swagger 2.0, comps is the document, which has parameters and
+// securityDefinitions instead
+func sharedComponents(comps *docMap, ctxt *context) {
+	if ctxt.api == nil {
+		return
+	}
+	api := ctxt.api
+	if len(api.Parameters) > 0 {
+		params := comps.child("parameters")
+		for _, h := range api.Parameters {
+			params.set(componentName(h.Name), apiParameter(h, ctxt))
+		}
+	}
+	if len(api.ResponseHeaders) > 0 && ctxt.oasVersion != oas20 {
+		headers := comps.child("headers")
+		for _, h := range api.ResponseHeaders {
+			headers.set(componentName(h.Name), responseHeader(h, ctxt))
+		}
+	}
+	if schemes := securitySchemes(ctxt); schemes != nil && schemes.len() > 0 {
+		if ctxt.oasVersion == oas20 {
+			comps.set("securityDefinitions", schemes)
+		} else {
+			comps.set("securitySchemes", schemes)
+		}
+	}
+}
+
+// the security schemes, without the types this version doesn't have
+func securitySchemes(ctxt *context) *docMap {
+	defs := schemeDefs(ctxt)
+	if defs == nil {
+		return nil
+	}
+	schemes := newDocMap()
+	for _, name := range defs.keys {
+		versioned := versionSchemes(name, defs.vals[name], ctxt)
+		if len(versioned) == 0 {
+			fmt.Printf("Warning: security scheme %s ignored, %s has no %s type\n", name, oasName(ctxt), schemeType(defs.vals[name]))
+			continue
+		}
+		for _, v := range versioned {
+			schemes.set(v.name, v.def)
+		}
+	}
+	return schemes
+}
+
+// the name of the version generated, e.g. OAS 3.0 or Swagger 2.0
+func oasName(ctxt *context) string {
+	if ctxt.oasVersion == oas20 {
+		return "Swagger 2.0"
+	}
+	return "OAS " + ctxt.oasVersion
+}
+
+// the security schemes as given, or nil
+func schemeDefs(ctxt *context) *docMap {
+	if ctxt.api == nil || ctxt.api.SecuritySchemes.Kind == 0 {
+		return nil
+	}
+	v, err := docFromYaml(&ctxt.api.SecuritySchemes)
+	defs, ok := v.(*docMap)
+	if err != nil || !ok {
+		fmt.Printf("API definition: securitySchemes is not a mapping\n")
+		os.Exit(2)
+	}
+	return defs
+}
+
+// a security scheme as written for the version generated
+type versionScheme struct {
+	name string
+	def  interface{}
+}
+
+// the names each security scheme has in the version generated, none if
+// it's left out
+func versionSchemeNames(ctxt *context) map[string][]string {
+	names := make(map[string][]string)
+	defs := schemeDefs(ctxt)
+	if defs == nil {
+		return names
+	}
+	for _, name := range defs.keys {
+		for _, v := range versionSchemes(name, defs.vals[name], ctxt) {
+			names[name] = append(names[name], v.name)
+		}
+	}
+	return names
+}
+
+// the type of a security scheme, e.g. oauth2, or http bearer
+func schemeType(def interface{}) string {
+	m, ok := def.(*docMap)
+	if !ok {
+		return "<nil>"
+	}
+	stype, _ := m.get("type")
+	if scheme, ok := m.get("scheme"); ok && stype == "http" {
+		return fmt.Sprintf("http %v", scheme)
+	}
+	return fmt.Sprint(stype)
+}
+
+// Swagger 2.0 names for the OAS 3 oauth2 flows
+var swaggerFlows = map[string]string{
+	"implicit":          "implicit",
+	"password":          "password",
+	"clientCredentials": "application",
+	"authorizationCode": "accessCode",
+}
+
+// a security scheme for the version generated: none if the version hasn't
+// the type (mutualTLS is new in 3.1)
+// Swagger 2.0 only has basic, apiKey and oauth2, so http basic is basic,
+// and an oauth2 scheme is a definition for each flow, named for the flow
+// if there are several, e.g. oauth2_clientCredentials
+func versionSchemes(name string, def interface{}, ctxt *context) []versionScheme {
+	m, ok := def.(*docMap)
+	if !ok {
+		return nil
+	}
+	stype, _ := m.get("type")
+	if ctxt.oasVersion != oas20 {
+		if ctxt.oasVersion == oas30 && stype == "mutualTLS" {
+			return nil
+		}
+		return []versionScheme{{name, def}}
+	}
+
+	swagger := func(t string) *docMap {
+		d := newDocMap().set("type", t)
+		if desc, ok := m.get("description"); ok {
+			d.set("description", desc)
+		}
+		return d
+	}
+	switch stype {
+	case "basic":
+		return []versionScheme{{name, def}}
+	case "apiKey":
+		if in, _ := m.get("in"); in == "cookie" {
+			return nil
+		}
+		return []versionScheme{{name, def}}
+	case "http":
+		if scheme, _ := m.get("scheme"); !strings.EqualFold(fmt.Sprint(scheme), "basic") {
+			return nil
+		}
+		return []versionScheme{{name, swagger("basic")}}
+	case "oauth2":
+		flows, ok := m.vals["flows"].(*docMap)
+		if !ok {
+			return []versionScheme{{name, def}} // written for Swagger 2.0
+		}
+		schemes := make([]versionScheme, 0, flows.len())
+		for _, flow := range flows.keys {
+			f, ok := flows.vals[flow].(*docMap)
+			if !ok || swaggerFlows[flow] == "" {
+				continue
+			}
+			d := swagger("oauth2").set("flow", swaggerFlows[flow])
+			for _, key := range []string{"authorizationUrl", "tokenUrl"} {
+				if v, ok := f.get(key); ok {
+					d.set(key, v)
+				}
+			}
+			scopes, ok := f.get("scopes")
+			if !ok {
+				scopes = newDocMap()
+			}
+			d.set("scopes", scopes)
+			schemes = append(schemes, versionScheme{name + "_" + flow, d})
+		}
+		if len(schemes) == 1 {
+			schemes[0].name = name
+		}
+		return schemes
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// asyncApi
+// Take the populated data structures and output an AsyncAPI document,
+// for messages carried over Kafka, MQ etc. rather than HTTP
+// the schemas are the same as in the OpenAPI document; each message
+// has headers for its type and the main business application header fields
+//
+// the channels are those of the API definition file, e.g.
+//
+//	asyncapi:
+//	  servers:
+//	    production: {host: broker.example.com:9092, protocol: kafka}
+//	  channels:
+//	    - name: payments
+//	      address: iso20022.pacs.008
+//	      action: receive
+//	      messages: [pacs.008.001.08]
+//
+// or else one channel for each message, which the application sends,
+// with the ISO 20022 message identifier as its address
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AsyncAPI versions
+const (
+	async26 = "2.6.0"
+	async30 = "3.0.0"
+)
+
+// operation actions, from the application's point of view
+const (
+	actionSend    = "send"
+	actionReceive = "receive"
+)
+
+// the AsyncAPI section of the API definition file
+type asyncDefinition struct {
+	Servers  yaml.Node      `yaml:"servers"` // copied as it is
+	Channels []asyncChannel `yaml:"channels"`
+}
+
+// a channel, and the operation of the application on it
+type asyncChannel struct {
+	Name        string   `yaml:"name"`
+	Address     string   `yaml:"address"` // the topic or queue; the name if omitted
+	Description string   `yaml:"description"`
+	Action      string   `yaml:"action"` // send (the default) or receive
+	OperationID string   `yaml:"operationId"`
+	Summary     string   `yaml:"summary"`
+	Messages    []string `yaml:"messages"`
+}
+
+// check the AsyncAPI section of an API definition
+func checkAsync(async *asyncDefinition) error {
+	if async.Servers.Kind != 0 && async.Servers.Kind != yaml.MappingNode {
+		return fmt.Errorf("asyncapi servers must be a mapping")
+	}
+	seen := make(map[string]bool)
+	for _, ch := range async.Channels {
+		if ch.Name == "" {
+			return fmt.Errorf("asyncapi: a channel has no name")
+		}
+		if seen[ch.Name] {
+			return fmt.Errorf("asyncapi: channel %s is defined twice", ch.Name)
+		}
+		seen[ch.Name] = true
+		if ch.Action != "" && ch.Action != actionSend && ch.Action != actionReceive {
+			return fmt.Errorf("asyncapi: channel %s action %q must be %s or %s", ch.Name, ch.Action, actionSend, actionReceive)
+		}
+		if len(ch.Messages) == 0 {
+			return fmt.Errorf("asyncapi: channel %s has no messages", ch.Name)
+		}
+	}
+	return nil
+}
+
+// the channels: those of the API definition, or one for each message
+func asyncChannels(ctxt *context) []asyncChannel {
+	if ctxt.api != nil && ctxt.api.AsyncAPI != nil && len(ctxt.api.AsyncAPI.Channels) > 0 {
+		return ctxt.api.AsyncAPI.Channels
+	}
+	channels := make([]asyncChannel, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		id := messageID(msg)
+		address := id
+		if msg.iso != nil {
+			address = msg.iso.String()
+		}
+		channels = append(channels, asyncChannel{Name: id, Address: address, Messages: []string{id}})
+	}
+	return channels
+}
+
+// entry point for writing
+func writeAsyncApi(f io.Writer, ctxt *context) {
+	// the schemas follow the JSON schema rules, without XML
+	actxt := *ctxt
+	actxt.oasVersion = oas31
+	actxt.xml = false
+	actxt.refPrefix = "#/components/schemas/"
+
+	doc := newDocMap()
+	doc.set("asyncapi", ctxt.asyncVersion)
+	info := doc.child("info").
+		set("title", docTitle(ctxt)).
+		set("version", docVersion(ctxt))
+	isoInfo(info, ctxt)
+	if servers := asyncServers(ctxt); servers != nil {
+		doc.set("servers", servers)
+	}
+	doc.set("defaultContentType", "application/json")
+
+	channels := asyncChannels(ctxt)
+	messages := newDocMap()
+	for _, ch := range channels {
+		for _, id := range ch.Messages {
+			msg := findMessage(id, ctxt)
+			if msg == nil {
+				fmt.Printf("AsyncAPI: channel %s message %s is not one of the messages\n", ch.Name, id)
+				os.Exit(2)
+			}
+			messages.set(componentName(id), asyncMessage(msg, &actxt))
+		}
+	}
+	if ctxt.asyncVersion == async26 {
+		asyncChannels26(doc, channels)
+	} else {
+		asyncChannels30(doc, channels)
+	}
+
+	comps := doc.child("components")
+	doc.comment("components", "---Component definitions---")
+	comps.set("schemas", buildSchemas(&actxt))
+	comps.set("messages", messages)
+
+	if err := encodeDoc(f, doc, ctxt.format); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// the servers: those of the API definition, or else the -servers URLs,
+// whose scheme is the protocol, e.g. kafka://broker.example.com:9092
+func asyncServers(ctxt *context) *docMap {
+	if ctxt.api != nil && ctxt.api.AsyncAPI != nil && ctxt.api.AsyncAPI.Servers.Kind != 0 {
+		v, err := docFromYaml(&ctxt.api.AsyncAPI.Servers)
+		servers, ok := v.(*docMap)
+		if err != nil || !ok {
+			fmt.Printf("API definition: asyncapi servers is not a mapping\n")
+			os.Exit(2)
+		}
+		return servers
+	}
+	if ctxt.servers == "" {
+		return nil
+	}
+	servers := newDocMap()
+	for i, s := range docServers(ctxt) {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			fmt.Printf("Warning: server %s ignored, it has no protocol or host\n", s)
+			continue
+		}
+		server := servers.child("server" + strconv.Itoa(i+1))
+		if ctxt.asyncVersion == async26 {
+			server.set("url", u.Host+u.Path)
+		} else {
+			server.set("host", u.Host)
+			if u.Path != "" {
+				server.set("pathname", u.Path)
+			}
+		}
+		server.set("protocol", u.Scheme)
+	}
+	return servers
+}
+
+// the message: its payload, and headers identifying it
+func asyncMessage(msg *message, ctxt *context) *docMap {
+	id := messageID(msg)
+	defID := id
+	if msg.iso != nil {
+		defID = msg.iso.String()
+	}
+	m := newDocMap()
+	m.set("name", id)
+	m.set("title", messageRoot(msg, ctxt))
+	m.set("contentType", "application/json")
+	m.set("headers", asyncHeaders(defID, ctxt))
+	m.set("payload", schemaRef(messageRoot(msg, ctxt), ctxt))
+	return m
+}
+
+// the message headers: the message type, and the main fields of the
+// business application header (head.001), named as properties are
+func asyncHeaders(defID string, ctxt *context) *docMap {
+	name := func(tag string) string {
+		for _, step := range renamers(ctxt) {
+			tag = step(tag)
+		}
+		return tag
+	}
+	m := newDocMap()
+	m.set("type", "object")
+	props := m.child("properties")
+	props.child(name("MsgDefIdr")).
+		set("type", "string").
+		set("description", "Message definition identifier, the message type").
+		set("const", defID)
+	props.child(name("BizMsgIdr")).
+		set("type", "string").
+		set("description", "Business message identifier").
+		set("maxLength", 35)
+	props.child(name("Fr")).
+		set("type", "string").
+		set("description", "The sender, e.g. a BIC")
+	props.child(name("To")).
+		set("type", "string").
+		set("description", "The receiver, e.g. a BIC")
+	props.child(name("CreDt")).
+		set("type", "string").
+		set("description", "Creation date and time").
+		set("format", "date-time")
+	m.set("required", flowStrings([]string{name("MsgDefIdr"), name("BizMsgIdr")}))
+	return m
+}
+
+// the action and operation ID of a channel
+func channelOperation(ch asyncChannel) (string, string) {
+	action := ch.Action
+	if action == "" {
+		action = actionSend
+	}
+	opID := ch.OperationID
+	if opID == "" {
+		opID = action + "_" + componentName(ch.Name)
+	}
+	return action, opID
+}
+
+// AsyncAPI 2.x channels, keyed by address, with their operations
+// subscribe is what the application sends, publish what it receives
+func asyncChannels26(doc *docMap, channels []asyncChannel) {
+	chans := doc.child("channels")
+	for _, ch := range channels {
+		address := ch.Address
+		if address == "" {
+			address = ch.Name
+		}
+		c := chans.child(address)
+		if ch.Description != "" {
+			c.set("description", ch.Description)
+		}
+		action, opID := channelOperation(ch)
+		verb := "subscribe"
+		if action == actionReceive {
+			verb = "publish"
+		}
+		op := c.child(verb)
+		op.set("operationId", opID)
+		if ch.Summary != "" {
+			op.set("summary", ch.Summary)
+		}
+		refs := make(docList, 0, len(ch.Messages))
+		for _, id := range ch.Messages {
+			refs = append(refs, newDocMap().set("$ref", "#/components/messages/"+componentName(id)))
+		}
+		if len(refs) == 1 {
+			op.set("message", refs[0])
+		} else {
+			op.child("message").set("oneOf", refs)
+		}
+	}
+}
+
+// AsyncAPI 3.0 channels, and the operations on them
+func asyncChannels30(doc *docMap, channels []asyncChannel) {
+	chans := doc.child("channels")
+	ops := doc.child("operations")
+	for _, ch := range channels {
+		key := componentName(ch.Name)
+		c := chans.child(key)
+		address := ch.Address
+		if address == "" {
+			address = ch.Name
+		}
+		c.set("address", address)
+		if ch.Description != "" {
+			c.set("description", ch.Description)
+		}
+		msgs := c.child("messages")
+		refs := make(docList, 0, len(ch.Messages))
+		for _, id := range ch.Messages {
+			name := componentName(id)
+			msgs.set(name, newDocMap().set("$ref", "#/components/messages/"+name))
+			refs = append(refs, newDocMap().set("$ref", "#/channels/"+key+"/messages/"+name))
+		}
+
+		action, opID := channelOperation(ch)
+		op := ops.child(opID)
+		op.set("action", action)
+		op.set("channel", newDocMap().set("$ref", "#/channels/"+key))
+		if ch.Summary != "" {
+			op.set("summary", ch.Summary)
+		}
+		op.set("messages", refs)
+	}
+}
@@ -0,0 +1,118 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// attrMapping
+// conventions for mapping XML attributes and element text to JSON
+
+package main
+
+import (
+	"sort"
+)
+
+// how an element with attributes is represented in JSON
+type attrMapping interface {
+	// the property holding the element text
+	valueName() string
+	// the property holding an attribute of an element
+	attrName(elem string, attr string) string
+	// are the attributes properties of the parent, next to the element?
+	flatten() bool
+}
+
+// an object holding the text and the attributes, e.g.
+//
+//	{"value": 1.00, "@Ccy": "GBP"}
+type objectMapping struct {
+	value  string
+	prefix string
+}
+
+func (m objectMapping) valueName() string {
+	return m.value
+}
+
+func (m objectMapping) attrName(elem string, attr string) string {
+	return m.prefix + attr
+}
+
+func (m objectMapping) flatten() bool {
+	return false
+}
+
+// the element holds the text, and the attributes are
+// flattened into the parent, e.g.
+//
+//	"IntrBkSttlmAmt": 1.00, "IntrBkSttlmAmtCcy": "GBP"
+type flatMapping struct{}
+
+func (m flatMapping) valueName() string {
+	return ""
+}
+
+func (m flatMapping) attrName(elem string, attr string) string {
+	return elem + attr
+}
+
+func (m flatMapping) flatten() bool {
+	return true
+}
+
+// the built-in conventions, selected with -attrs
+// -valuename overrides the name of the value property
+var attrMappings = map[string]func(value string) attrMapping{
+	"at": func(value string) attrMapping {
+		return objectMapping{orDefault(value, "value"), "@"}
+	},
+	"plain": func(value string) attrMapping {
+		return objectMapping{orDefault(value, "value"), ""}
+	},
+	"dollar": func(value string) attrMapping {
+		return objectMapping{orDefault(value, "$value"), "_"}
+	},
+	"flatten": func(value string) attrMapping {
+		return flatMapping{}
+	},
+}
+
+// the names of the conventions, for the usage message
+func attrMappingNames() []string {
+	names := make([]string, 0, len(attrMappings))
+	for name := range attrMappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func orDefault(s string, dflt string) string {
+	if s == "" {
+		return dflt
+	}
+	return s
+}
+
+// the simple type of an element, if its attributes are flattened into the parent
+func flatAttrType(el *element, ctxt *context) (*simpleType, bool) {
+	if !ctxt.attrMap.flatten() {
+		return nil, false
+	}
+	simple, ok := ctxt.simpleTypes[el.etype]
+	if !ok || len(simple.attrs) == 0 {
+		return nil, false
+	}
+	return simple, true
+}
@@ -0,0 +1,130 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// callbacks
+// ISO 20022 flows are asynchronous: e.g. a pacs.008 is answered later
+// by a pacs.002 status report, which the server sends to the client
+// -callback adds the report as a callback of each default operation,
+// and -webhook as a webhook (OAS 3.1); an API definition can give
+// callbacks for each operation, and webhooks
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// the name of the callback or webhook for the default paths
+const statusReport = "statusReport"
+
+// the default runtime expression for the callback URL
+const defaultCallbackURL = "{$request.header.X-Callback-URL}"
+
+// the operation that receives a status report
+func reportOperation(id string) apiOperation {
+	return apiOperation{
+		Method:    "post",
+		Request:   id,
+		Responses: map[string]apiResponse{"204": {Description: "Status report received"}},
+	}
+}
+
+// is a message only sent by the server, as a callback or webhook?
+// if so, it has no path of its own
+func serverMessage(msg *message, ctxt *context) bool {
+	id := messageID(msg)
+	return id == ctxt.callback || id == ctxt.webhook
+}
+
+// add the status report callback to a default operation
+func defaultCallback(op *docMap, ctxt *context) {
+	if ctxt.callback == "" {
+		return
+	}
+	cb := op.child("callbacks").child(statusReport).child(ctxt.callbackURL).child("post")
+	apiOperationDoc(cb, reportOperation(ctxt.callback), ctxt)
+}
+
+// add the status report callback to the operations of a template that
+// send a message (those with a request body), as the template's paths
+// replace the default ones
+func templateCallbacks(doc *docMap, ctxt *context) {
+	if ctxt.callback == "" || ctxt.oasVersion == oas20 {
+		return
+	}
+	paths, ok := doc.vals["paths"].(*docMap)
+	if !ok {
+		return
+	}
+	found := false
+	for _, path := range paths.keys {
+		item, ok := paths.vals[path].(*docMap)
+		if !ok {
+			continue
+		}
+		for _, method := range apiMethods {
+			op, ok := item.vals[method].(*docMap)
+			if !ok {
+				continue
+			}
+			if _, ok := op.get("requestBody"); !ok {
+				continue
+			}
+			found = true
+			if cbs, ok := op.vals["callbacks"].(*docMap); ok {
+				if _, ok := cbs.get(statusReport); ok {
+					continue
+				}
+			}
+			defaultCallback(op, ctxt)
+		}
+	}
+	if !found {
+		fmt.Printf("Warning: -callback ignored, the template has no operation with a request body\n")
+	}
+}
+
+// add the webhooks, after the paths
+// they are the status report webhook, or those of the API definition
+func buildWebhooks(doc *docMap, ctxt *context) {
+	hooks := make(map[string]apiOperation)
+	if ctxt.webhook != "" {
+		hooks[statusReport] = reportOperation(ctxt.webhook)
+	}
+	if ctxt.api != nil {
+		for name, op := range ctxt.api.Webhooks {
+			hooks[name] = op
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	if ctxt.oasVersion != oas31 {
+		fmt.Printf("Warning: webhooks ignored, they need OAS 3.1\n")
+		return
+	}
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	webhooks := doc.child("webhooks")
+	for _, name := range names {
+		op := hooks[name]
+		apiOperationDoc(webhooks.child(name).child(op.Method), op, ctxt)
+	}
+}
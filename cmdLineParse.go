@@ -24,63 +24,227 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 )
 
 func cmdLineParse(ctxt *context) {
-	inFilePtr := flag.String("in", "", "input xsd file name")
+	inFilePtr := flag.String("in", "", "input xsd file names (comma delimited)")
 	outFilePtr := flag.String("out", "", "output yaml file name")
-	maskFilePtr := flag.String("mask", "", "mask file name (input)")
-	pathFilePtr := flag.String("path", "", "path file name (output)")
-	exFilePtr := flag.String("ex", "", "example file name (output)")
+	maskFilePtr := flag.String("mask", "", "mask file names (input, one per xsd)")
+	pathFilePtr := flag.String("path", "", "path file names (output, one per xsd)")
+	exFilePtr := flag.String("ex", "", "example file names (output, one per xsd)")
 	templateFilePtr := flag.String("template", "", "template file (input)")
+	apiFilePtr := flag.String("api", "", "API definition file (input)")
 	serversPtr := flag.String("servers", "", "server list (input)")
-	endpointPtr := flag.String("endpoint", "", "path to endpoint (input)")
+	endpointPtr := flag.String("endpoint", "", "paths to endpoints (input, one per xsd)")
 	titlePtr := flag.String("title", "", "title of specification (input)")
 	licPtr := flag.Bool("lic", false, "print license info")
 	fixupPtr := flag.Bool("fixup", false, "Fix Swagger uppercase bug")
 	allPtr := flag.Bool("all", false, "all elements")
+	decimalPtr := flag.String("decimal", decimalNumber, "represent decimals as number or string")
+	formatPtr := flag.String("format", formatYaml, "output format, yaml or json")
+	oasPtr := flag.String("oas", oas30, "OpenAPI version, 2.0, 3.0 or 3.1")
+	jsonPtr := flag.String("jsonschema", "", "JSON schema file name (output)")
+	draftPtr := flag.String("draft", draft2020, "JSON schema draft, 07 or 2020-12")
+	xmlPtr := flag.Bool("xml", false, "describe the XML format too")
+	xmlPrefixPtr := flag.String("xmlprefix", "", "namespace prefix for XML")
+	attrsPtr := flag.String("attrs", "at", "JSON convention for attributes: "+strings.Join(attrMappingNames(), ", "))
+	valueNamePtr := flag.String("valuename", "", "name of the value property of elements with attributes")
+	expandPtr := flag.Bool("expand", false, "expand ISO 20022 abbreviations in property names")
+	abbrevsPtr := flag.String("abbrevs", "", "abbreviations dictionary file (input)")
+	namingPtr := flag.String("naming", namingKeep, "naming strategy for properties: "+strings.Join(namingNames(), ", "))
+	reasonsPtr := flag.String("reasons", "", "status reason code list for error responses (input)")
+	callbackPtr := flag.String("callback", "", "message sent back as a callback, e.g. a status report")
+	callbackURLPtr := flag.String("callbackurl", defaultCallbackURL, "runtime expression for the callback URL")
+	webhookPtr := flag.String("webhook", "", "message sent as a webhook (OAS 3.1)")
+	asyncPtr := flag.String("asyncapi", "", "AsyncAPI file name (output)")
+	asyncVersionPtr := flag.String("asyncversion", "3.0", "AsyncAPI version, 2.6 or 3.0")
+	protoPtr := flag.String("proto", "", "proto3 file name (output)")
+	protoNumsPtr := flag.String("protonumbers", "", "proto field numbers file (input and output)")
+	protoPackagePtr := flag.String("protopackage", "", "proto package name")
+	avroPtr := flag.String("avro", "", "Avro schema file name (output)")
+	avroNamespacePtr := flag.String("avronamespace", "", "Avro namespace")
+	goPtr := flag.String("go", "", "Go source file name (output)")
+	goPackagePtr := flag.String("gopackage", "", "Go package name")
+	tsPtr := flag.String("ts", "", "TypeScript file name (output)")
+	sqlPtr := flag.String("sql", "", "SQL DDL file name (output)")
+	sqlDialectPtr := flag.String("sqldialect", sqlPostgres, "SQL dialect, postgres or sqlite")
+	graphqlPtr := flag.String("graphql", "", "GraphQL schema file name (output)")
 
 	flag.Parse()
 
 	if *inFilePtr == "" || *outFilePtr == "" {
 		fmt.Printf(
-			`Usage: %s -in xsdfile -out yamlfile
+			`Usage: %s -in xsdfiles -out yamlfile
+-in takes a comma delimited list of XSDs, combined into one spec;
+-mask, -path, -ex and -endpoint take a list with an entry for each XSD
 Optional parameters:
--mask maskfile
--path pathfile
--ex examplefile
+-mask maskfiles
+-path pathfiles
+-ex examplefiles
 -template templatefile
+-api API definition file (operations and their messages, security and shared headers)
 -servers server list (comma delimited)
--endpoint relative path to endpoint (appended to server URL)
+-endpoint relative paths to endpoints (appended to server URL)
 -title title of specification
 -lic (print license)
 -fixup (fix Swagger uppercase bug)
--all (include optional elements in path file)`, filepath.Base(os.Args[0]))
+-all (include optional elements in path file)
+-decimal number|string (representation of XSD decimals, default number)
+-format yaml|json (output format, default yaml)
+-oas 2.0|3.0|3.1 (OpenAPI version, default 3.0; 2.0 is Swagger)
+-jsonschema jsonschemafile
+-draft 07|2020-12 (JSON schema draft, default 2020-12)
+-xml (describe the XML format too, and accept application/xml)
+-xmlprefix namespace prefix for XML
+-attrs at|plain|dollar|flatten (JSON convention for attributes, default at)
+-valuename name of the value property of elements with attributes
+-expand (expand ISO 20022 abbreviations in property names)
+-abbrevs abbreviations dictionary file (implies -expand)
+-naming keep|lowerCamel|snake|kebab (naming strategy for properties, default keep)
+-reasons status reason code list (ExternalStatusReason1Code) for error responses
+-callback message sent back as a callback of each path, e.g. pacs.002.001.10
+-callbackurl runtime expression for the callback URL (default {$request.header.X-Callback-URL})
+-webhook message sent as a webhook (OAS 3.1), e.g. pacs.002.001.10
+-asyncapi asyncapifile
+-asyncversion 2.6|3.0 (AsyncAPI version, default 3.0)
+-proto protofile
+-protonumbers field numbers file, kept so the numbers don't change
+-protopackage proto package name (default from the ISO 20022 message)
+-avro avroschemafile
+-avronamespace Avro namespace (default from the ISO 20022 message)
+-go gofile
+-gopackage Go package name (default iso20022)
+-ts typescriptfile (.ts or .d.ts)
+-sql sqlfile
+-sqldialect postgres|sqlite (SQL dialect, default postgres)
+-graphql graphqlfile
+or: %s diff [-format text|json|markdown] [-mask maskfile] [-out file] oldxsd newxsd
+(compare two versions of a message)`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
+		os.Exit(1)
+	}
+	if *decimalPtr != decimalNumber && *decimalPtr != decimalString {
+		fmt.Printf("Invalid -decimal %s: must be %s or %s\n", *decimalPtr, decimalNumber, decimalString)
+		os.Exit(1)
+	}
+	if *formatPtr != formatYaml && *formatPtr != formatJson {
+		fmt.Printf("Invalid -format %s: must be %s or %s\n", *formatPtr, formatYaml, formatJson)
+		os.Exit(1)
+	}
+	if *oasPtr != oas20 && *oasPtr != oas30 && *oasPtr != oas31 {
+		fmt.Printf("Invalid -oas %s: must be %s, %s or %s\n", *oasPtr, oas20, oas30, oas31)
+		os.Exit(1)
+	}
+	if *draftPtr != draft07 && *draftPtr != draft2020 {
+		fmt.Printf("Invalid -draft %s: must be %s or %s\n", *draftPtr, draft07, draft2020)
+		os.Exit(1)
+	}
+	newAttrMap, ok := attrMappings[*attrsPtr]
+	if !ok {
+		fmt.Printf("Invalid -attrs %s: must be one of %s\n", *attrsPtr, strings.Join(attrMappingNames(), ", "))
+		os.Exit(1)
+	}
+
+	if *sqlDialectPtr != sqlPostgres && *sqlDialectPtr != sqlSqlite {
+		fmt.Printf("Invalid -sqldialect %s: must be %s or %s\n", *sqlDialectPtr, sqlPostgres, sqlSqlite)
 		os.Exit(1)
 	}
 
-	ctxt.inFile = *inFilePtr
-	ctxt.inFileBase = filepath.Base(ctxt.inFile)
+	if _, ok := namingStrategies[*namingPtr]; !ok {
+		fmt.Printf("Invalid -naming %s: must be one of %s\n", *namingPtr, strings.Join(namingNames(), ", "))
+		os.Exit(1)
+	}
+
+	ctxt.messages = messageList(*inFilePtr, *maskFilePtr, *pathFilePtr, *exFilePtr, *endpointPtr)
 	ctxt.outFile = *outFilePtr
 	ctxt.templateFile = *templateFilePtr
+	ctxt.apiFile = *apiFilePtr
 	ctxt.outFileBase = filepath.Base(ctxt.outFile)
 	ctxt.servers = *serversPtr
-	ctxt.endpoint = *endpointPtr
 	ctxt.title = *titlePtr
 	ctxt.printLicense = *licPtr
 	ctxt.fixUppercase = *fixupPtr
 	ctxt.all = *allPtr
-
-	if *maskFilePtr != "" {
-		ctxt.maskFile = *maskFilePtr
-		ctxt.maskFileBase = filepath.Base(ctxt.maskFile)
+	ctxt.decimalMode = *decimalPtr
+	ctxt.format = *formatPtr
+	ctxt.oasVersion = *oasPtr
+	switch *asyncVersionPtr {
+	case "2.6", async26:
+		ctxt.asyncVersion = async26
+	case "3.0", async30:
+		ctxt.asyncVersion = async30
+	default:
+		fmt.Printf("Invalid -asyncversion %s: must be 2.6 or 3.0\n", *asyncVersionPtr)
+		os.Exit(1)
+	}
+	ctxt.asyncFile = *asyncPtr
+	ctxt.protoFile = *protoPtr
+	ctxt.protoNumFile = *protoNumsPtr
+	ctxt.protoPackage = *protoPackagePtr
+	ctxt.avroFile = *avroPtr
+	ctxt.avroNamespace = *avroNamespacePtr
+	ctxt.goFile = *goPtr
+	ctxt.goPackage = *goPackagePtr
+	ctxt.tsFile = *tsPtr
+	ctxt.sqlFile = *sqlPtr
+	ctxt.sqlDialect = *sqlDialectPtr
+	ctxt.graphqlFile = *graphqlPtr
+	ctxt.jsonFile = *jsonPtr
+	ctxt.jsonDraft = *draftPtr
+	ctxt.xml = *xmlPtr
+	ctxt.xmlPrefix = *xmlPrefixPtr
+	ctxt.attrMap = newAttrMap(*valueNamePtr)
+	ctxt.expand = *expandPtr || *abbrevsPtr != ""
+	ctxt.abbrevFile = *abbrevsPtr
+	ctxt.naming = *namingPtr
+	ctxt.reasonFile = *reasonsPtr
+	ctxt.callback = *callbackPtr
+	ctxt.callbackURL = *callbackURLPtr
+	ctxt.webhook = *webhookPtr
+	if ctxt.callback != "" && findMessage(ctxt.callback, ctxt) == nil {
+		fmt.Printf("Invalid -callback %s: must be one of the -in XSDs, without .xsd\n", ctxt.callback)
+		os.Exit(1)
+	}
+	if ctxt.webhook != "" && findMessage(ctxt.webhook, ctxt) == nil {
+		fmt.Printf("Invalid -webhook %s: must be one of the -in XSDs, without .xsd\n", ctxt.webhook)
+		os.Exit(1)
 	}
-	if *pathFilePtr != "" {
-		ctxt.pathFile = *pathFilePtr
-		ctxt.pathFileBase = filepath.Base(ctxt.pathFile)
+}
+
+// split the lists of files and endpoints into messages, one for each XSD
+// the other lists can be shorter, or have empty entries
+func messageList(in string, masks string, paths string, exs string, endpoints string) []*message {
+	r := regexp.MustCompile("\\s*,\\s*")
+	split := func(flagName string, list string, n int) []string {
+		items := make([]string, n)
+		if list == "" {
+			return items
+		}
+		parts := r.Split(list, -1)
+		if len(parts) > n {
+			fmt.Printf("Invalid -%s %s: more entries than -in\n", flagName, list)
+			os.Exit(1)
+		}
+		copy(items, parts)
+		return items
 	}
-	if *exFilePtr != "" {
-		ctxt.exFile = *exFilePtr
-		ctxt.exFileBase = filepath.Base(ctxt.exFile)
+	inFiles := r.Split(in, -1)
+	maskFiles := split("mask", masks, len(inFiles))
+	pathFiles := split("path", paths, len(inFiles))
+	exFiles := split("ex", exs, len(inFiles))
+	endpointList := split("endpoint", endpoints, len(inFiles))
+
+	msgs := make([]*message, 0, len(inFiles))
+	for i, inFile := range inFiles {
+		msgs = append(msgs, &message{
+			inFile:     inFile,
+			inFileBase: filepath.Base(inFile),
+			maskFile:   maskFiles[i],
+			pathFile:   pathFiles[i],
+			exFile:     exFiles[i],
+			endpoint:   endpointList[i],
+		})
 	}
+	return msgs
 }
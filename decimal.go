@@ -0,0 +1,226 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// decimal
+// handle totalDigits and fractionDigits on XSD decimals
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decimal representations
+const (
+	decimalNumber = "number" // JSON number, with multipleOf and maximum
+	decimalString = "string" // JSON string, with a pattern
+)
+
+// is the simple type an XSD decimal (rather than an integer or float)
+func isDecimal(simple *simpleType) bool {
+	return localName(simple.base) == "decimal"
+}
+
+// can the value be negative?
+func decimalSigned(simple *simpleType) bool {
+	return simple.minInclusive < 0 && simple.minExclusive < 0
+}
+
+// the digit limits to use: -1 means unrestricted
+// fractionDigits can't be more than totalDigits
+func decimalDigits(simple *simpleType) (int, int) {
+	total, fraction := simple.totalDigits, simple.fractionDigits
+	if total > -1 && fraction > total {
+		fraction = total
+	}
+	return total, fraction
+}
+
+// build a pattern for a decimal held as a string
+// totalDigits counts digits either side of the point, so each
+// possible number of fraction digits gets its own alternative
+// e.g. totalDigits=3 fractionDigits=1 gives
+//
+//	^(?:(?:0|[1-9][0-9]{0,2})|(?:0|[1-9][0-9]{0,1})[.][0-9])$
+//
+// totalDigits on its own allows up to that many fraction digits
+// the point is written as [.] so the pattern needs no escaping
+// the bounds are whole numbers and can't be negative, so the pattern
+// only checks the bounds of 0: the sign, and whether it can be zero
+func decimalPattern(simple *simpleType) string {
+	total, fraction := decimalDigits(simple)
+	if total > -1 && fraction < 0 {
+		fraction = total
+	}
+	switch {
+	case simple.minInclusive > 0 || simple.minExclusive > -1:
+		return "^" + decimalNonZero(total, fraction) + "$"
+	case simple.minInclusive == 0:
+		return "^" + decimalUnsigned(total, fraction) + "$"
+	case simple.maxExclusive == 0:
+		return "^-" + decimalNonZero(total, fraction) + "$"
+	case simple.maxInclusive == 0:
+		return "^(?:-" + decimalUnsigned(total, fraction) + "|" + decimalZero(fraction) + ")$"
+	}
+	return "^-?" + decimalUnsigned(total, fraction) + "$"
+}
+
+// the digits of a decimal, without the sign
+func decimalUnsigned(total int, fraction int) string {
+	if total < 0 {
+		switch {
+		case fraction < 0:
+			return "[0-9]+(?:[.][0-9]+)?"
+		case fraction == 0:
+			return "[0-9]+"
+		default:
+			return fmt.Sprintf("[0-9]+(?:[.][0-9]{1,%d})?", fraction)
+		}
+	}
+	alts := make([]string, 0)
+	for k := 0; k <= fraction; k++ {
+		intPart := "0"
+		if total-k > 1 {
+			intPart = fmt.Sprintf("(?:0|[1-9][0-9]{0,%d})", total-k-1)
+		} else if total-k == 1 {
+			intPart = "[0-9]"
+		}
+		alts = append(alts, intPart+decimalFraction(k))
+	}
+	return "(?:" + strings.Join(alts, "|") + ")"
+}
+
+// the digits of a decimal that isn't zero, without the sign: either the
+// whole part isn't zero, or it is and the fraction isn't
+func decimalNonZero(total int, fraction int) string {
+	if total < 0 {
+		switch {
+		case fraction < 0:
+			return "(?:0*[1-9][0-9]*(?:[.][0-9]+)?|0+[.]" + nonZeroFraction(fraction) + ")"
+		case fraction == 0:
+			return "0*[1-9][0-9]*"
+		default:
+			return fmt.Sprintf("(?:0*[1-9][0-9]*(?:[.][0-9]{1,%d})?|0+[.]%s)", fraction, nonZeroFraction(fraction))
+		}
+	}
+	alts := make([]string, 0)
+	for k := 0; k <= fraction; k++ {
+		if total-k > 1 {
+			alts = append(alts, fmt.Sprintf("[1-9][0-9]{0,%d}", total-k-1)+decimalFraction(k))
+		} else if total-k == 1 {
+			alts = append(alts, "[1-9]"+decimalFraction(k))
+		}
+	}
+	if fraction > 0 {
+		alts = append(alts, "0[.]"+nonZeroFraction(fraction))
+	}
+	return "(?:" + strings.Join(alts, "|") + ")"
+}
+
+// exactly k fraction digits, with the point
+func decimalFraction(k int) string {
+	switch k {
+	case 0:
+		return ""
+	case 1:
+		return "[.][0-9]"
+	}
+	return fmt.Sprintf("[.][0-9]{%d}", k)
+}
+
+// up to fraction digits (-1 for any number), not all zero
+func nonZeroFraction(fraction int) string {
+	if fraction < 0 {
+		return "0*[1-9][0-9]*"
+	}
+	alts := make([]string, 0, fraction)
+	for i := 0; i < fraction; i++ {
+		alt := ""
+		switch {
+		case i == 1:
+			alt = "0"
+		case i > 1:
+			alt = fmt.Sprintf("0{%d}", i)
+		}
+		alt += "[1-9]"
+		if rest := fraction - 1 - i; rest > 0 {
+			alt += fmt.Sprintf("[0-9]{0,%d}", rest)
+		}
+		alts = append(alts, alt)
+	}
+	return "(?:" + strings.Join(alts, "|") + ")"
+}
+
+// zero, with up to fraction zeros after the point (-1 for any number)
+func decimalZero(fraction int) string {
+	switch {
+	case fraction < 0:
+		return "0(?:[.]0+)?"
+	case fraction == 0:
+		return "0"
+	case fraction == 1:
+		return "0(?:[.]0)?"
+	}
+	return fmt.Sprintf("0(?:[.]0{1,%d})?", fraction)
+}
+
+// the step between values of a decimal held as a number, e.g. 0.00001
+// empty if fractionDigits is not restricted
+func decimalMultipleOf(simple *simpleType) string {
+	_, fraction := decimalDigits(simple)
+	switch {
+	case fraction < 0:
+		return ""
+	case fraction == 0:
+		return "1"
+	}
+	return "0." + strings.Repeat("0", fraction-1) + "1"
+}
+
+// the largest value allowed by the digit restrictions, e.g. 999.99
+// empty if totalDigits is not restricted; totalDigits on its own
+// allows a whole number of that many digits, e.g. 99999
+func decimalMaximum(simple *simpleType) string {
+	total, fraction := decimalDigits(simple)
+	if total < 0 {
+		return ""
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+	max := strings.Repeat("9", total-fraction)
+	if max == "" {
+		max = "0"
+	}
+	if fraction > 0 {
+		max += "." + strings.Repeat("9", fraction)
+	}
+	return max
+}
+
+// generate sample data for a decimal, quoted if held as a string
+func sampleDecimal(s *simpleType, ctxt *context) string {
+	patt := decimalPattern(s)
+	str, err := generate(patt, 5)
+	if err != nil {
+		panic(err)
+	}
+	if ctxt.decimalMode == decimalString {
+		return fmt.Sprintf("\"%v\"", str)
+	}
+	return str
+}
@@ -0,0 +1,304 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// docModel
+// in-memory document tree, serialised as YAML or JSON
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// output formats
+const (
+	formatYaml = "yaml"
+	formatJson = "json"
+)
+
+// a mapping that keeps its keys in insertion order,
+// so the output follows the order of the XSD
+// values are *docMap, docList, docFlow, docNumber, string, int or bool
+type docMap struct {
+	keys     []string
+	vals     map[string]interface{}
+	comments map[string][]string
+}
+
+// a block sequence
+type docList []interface{}
+
+// a short sequence written inline, e.g. required: [a, b]
+type docFlow []interface{}
+
+// a number held as text so it is written exactly, e.g. 0.00001
+type docNumber string
+
+// create an empty mapping
+func newDocMap() *docMap {
+	return &docMap{
+		keys:     make([]string, 0),
+		vals:     make(map[string]interface{}),
+		comments: make(map[string][]string),
+	}
+}
+
+// set a value, keeping the position of an existing key
+func (m *docMap) set(key string, val interface{}) *docMap {
+	if _, ok := m.vals[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.vals[key] = val
+	return m
+}
+
+// set a value, putting a new key before another
+// (or at the end, if that isn't there)
+func (m *docMap) setBefore(before string, key string, val interface{}) *docMap {
+	if _, ok := m.vals[key]; ok {
+		return m.set(key, val)
+	}
+	m.set(key, val)
+	for i, k := range m.keys {
+		if k == before {
+			copy(m.keys[i+1:], m.keys[i:len(m.keys)-1])
+			m.keys[i] = key
+			break
+		}
+	}
+	return m
+}
+
+// get a value
+func (m *docMap) get(key string) (interface{}, bool) {
+	val, ok := m.vals[key]
+	return val, ok
+}
+
+// get a nested mapping, creating it if necessary
+func (m *docMap) child(key string) *docMap {
+	if val, ok := m.vals[key]; ok {
+		if c, ok := val.(*docMap); ok {
+			return c
+		}
+	}
+	c := newDocMap()
+	m.set(key, c)
+	return c
+}
+
+// remove a key
+func (m *docMap) remove(key string) {
+	if _, ok := m.vals[key]; !ok {
+		return
+	}
+	delete(m.vals, key)
+	delete(m.comments, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// the number of keys
+func (m *docMap) len() int {
+	return len(m.keys)
+}
+
+// attach a comment to a key
+// comments appear in YAML only, JSON has no way to hold them
+func (m *docMap) comment(key string, format string, v ...interface{}) {
+	m.comments[key] = append(m.comments[key], fmt.Sprintf(format, v...))
+}
+
+// JSON encoding, in key order
+func (m *docMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(m.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteString(":")
+		b.Write(vb)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// JSON encoding of a number held as text
+func (d docNumber) MarshalJSON() ([]byte, error) {
+	return []byte(d), nil
+}
+
+// convert the document model into YAML nodes, in key order with comments
+func yamlNode(v interface{}) (*yaml.Node, error) {
+	switch val := v.(type) {
+	case *docMap:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range val.keys {
+			kn := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+			if c := val.comments[k]; len(c) > 0 {
+				kn.HeadComment = strings.Join(c, "\n")
+			}
+			vn, err := yamlNode(val.vals[k])
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, kn, vn)
+		}
+		return n, nil
+	case docList:
+		return yamlSeq(val, 0)
+	case docFlow:
+		return yamlSeq(val, yaml.FlowStyle)
+	case docNumber:
+		tag := "!!int"
+		if strings.ContainsAny(string(val), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(val)}, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: val}, nil
+	}
+	n := &yaml.Node{}
+	err := n.Encode(v)
+	return n, err
+}
+
+// convert a sequence into YAML nodes
+func yamlSeq(items []interface{}, style yaml.Style) (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: style}
+	for _, item := range items {
+		in, err := yamlNode(item)
+		if err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, in)
+	}
+	return n, nil
+}
+
+// convert a list of strings for inline output
+func flowStrings(strs []string) docFlow {
+	l := make(docFlow, 0, len(strs))
+	for _, s := range strs {
+		l = append(l, s)
+	}
+	return l
+}
+
+// convert parsed YAML (e.g. a template) into the document model
+func docFromYaml(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return newDocMap(), nil
+		}
+		return docFromYaml(n.Content[0])
+	case yaml.MappingNode:
+		m := newDocMap()
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v, err := docFromYaml(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m.set(n.Content[i].Value, v)
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		l := make(docList, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := docFromYaml(c)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+	case yaml.AliasNode:
+		return docFromYaml(n.Alias)
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!int", "!!float":
+			return docNumber(n.Value), nil
+		case "!!bool":
+			var b bool
+			err := n.Decode(&b)
+			return b, err
+		case "!!null":
+			return nil, nil
+		}
+		return n.Value, nil
+	}
+	return nil, fmt.Errorf("line %d: unexpected YAML node", n.Line)
+}
+
+// parse YAML text into the document model
+func parseDoc(text string) (*docMap, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &n); err != nil {
+		return nil, err
+	}
+	v, err := docFromYaml(&n)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(*docMap)
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+	return m, nil
+}
+
+// serialise a document in the given format
+func encodeDoc(f io.Writer, doc interface{}, format string) error {
+	if format == formatJson {
+		b, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(b, '\n'))
+		return err
+	}
+	n, err := yamlNode(doc)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(tsz)
+	if err := enc.Encode(n); err != nil {
+		return err
+	}
+	return enc.Close()
+}
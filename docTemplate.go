@@ -0,0 +1,220 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// docTemplate
+// a template is a Go text/template over the model, which must produce yaml
+// each top-level section of the result replaces that section of the
+// generated document, so a template can give a whole document or only
+// (say) the paths; components are merged, so schemas can be added
+//
+// the old substitution strings $TITLE, $PATH, $URLS and $ROOT still work
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// the data a template can use
+type templateData struct {
+	Title     string            // the document title
+	Version   string            // the OAS version, e.g. 3.0
+	Servers   []string          // the server URLs
+	Path      string            // the endpoint of the first message
+	Root      string            // the message type of the first message
+	Namespace string            // the namespace of the types, if they share one
+	Messages  []templateMessage // the messages, in command line order
+	Types     []string          // the names of the types output, sorted
+	Flags     map[string]string // the command line flags, e.g. .Flags.decimal
+}
+
+// a message, for a template
+type templateMessage struct {
+	ID        string         // the XSD file name without .xsd, e.g. pacs.008.001.08
+	File      string         // the XSD file name
+	Path      string         // the endpoint
+	Root      string         // the message type, e.g. FIToFICustomerCreditTransferV08
+	Element   string         // the root element, e.g. Document
+	Document  string         // the type of the root element
+	Namespace string         // the target namespace
+	Mask      []templateMask // the mask lines, if there is a mask
+
+	// the parts of the ISO 20022 message identifier, if the namespace has one
+	BusinessArea   string // e.g. pacs
+	Functionality  string // e.g. 008
+	Variant        string // e.g. 001
+	MessageVersion string // e.g. 08
+}
+
+// a mask line, with its comment
+type templateMask struct {
+	Path    string
+	Comment string
+}
+
+// the functions a template can use
+func templateFuncs(ctxt *context) template.FuncMap {
+	return template.FuncMap{
+		// the reference to a schema, e.g. #/components/schemas/Max35Text
+		"ref": func(name string) string {
+			return schemaRef(name, ctxt).vals["$ref"].(string)
+		},
+		"join":  strings.Join,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		// a yaml single-quoted string
+		"quote": func(s string) string {
+			return "'" + strings.Replace(s, "'", "''", -1) + "'"
+		},
+	}
+}
+
+// the data for the template
+func buildTemplateData(ctxt *context) templateData {
+	data := templateData{
+		Title:     docTitle(ctxt),
+		Version:   ctxt.oasVersion,
+		Servers:   docServers(ctxt),
+		Namespace: ctxt.namespace,
+		Flags:     make(map[string]string),
+	}
+	for _, msg := range ctxt.messages {
+		tm := templateMessage{
+			ID:        messageID(msg),
+			File:      msg.inFileBase,
+			Path:      messageEndpoint(msg, ctxt),
+			Root:      messageRoot(msg, ctxt),
+			Element:   msg.root.name,
+			Document:  msg.root.etype,
+			Namespace: msg.namespace,
+		}
+		if msg.iso != nil {
+			tm.BusinessArea = msg.iso.area
+			tm.Functionality = msg.iso.functionality
+			tm.Variant = msg.iso.variant
+			tm.MessageVersion = msg.iso.version
+		}
+		for _, line := range msg.mask {
+			tm.Mask = append(tm.Mask, templateMask{line.path, line.comment})
+		}
+		data.Messages = append(data.Messages, tm)
+	}
+	data.Path = data.Messages[0].Path
+	data.Root = data.Messages[0].Root
+
+	for _, simple := range ctxt.simpleTypes {
+		if simple.include {
+			data.Types = append(data.Types, simple.name)
+		}
+	}
+	for _, cmplx := range ctxt.complexTypes {
+		if isOutput(cmplx.name, cmplx.include, "", ctxt) {
+			data.Types = append(data.Types, cmplx.name)
+		}
+	}
+	sort.Strings(data.Types)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		data.Flags[f.Name] = f.Value.String()
+	})
+	return data
+}
+
+// run the template and lay the result over the document
+func applyTemplate(doc *docMap, ctxt *context) {
+	tmpl, err := template.New(filepath.Base(ctxt.templateFile)).
+		Funcs(templateFuncs(ctxt)).
+		Option("missingkey=error").
+		Parse(ctxt.hdrTemplate)
+	if err != nil {
+		fmt.Printf("Template %v parse err %v\n", ctxt.templateFile, err)
+		os.Exit(2)
+	}
+	data := buildTemplateData(ctxt)
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		fmt.Printf("Template %v err %v\n", ctxt.templateFile, err)
+		os.Exit(2)
+	}
+
+	// the old substitution strings
+	urls := ""
+	for _, s := range data.Servers {
+		urls += "  - url: " + s + "\n"
+	}
+	r := strings.NewReplacer(
+		"$TITLE", data.Title,
+		"$PATH", data.Path,
+		"$URLS", urls,
+		"$ROOT", data.Root)
+	tdoc, err := parseDoc(r.Replace(b.String()))
+	if err != nil {
+		fmt.Printf("Template %v parse err %v\n", ctxt.templateFile, err)
+		os.Exit(2)
+	}
+	overlayDoc(doc, tdoc, ctxt)
+	if _, ok := tdoc.get("paths"); ok {
+		templateCallbacks(doc, ctxt)
+	}
+}
+
+// lay the sections of a template over the document
+// new sections go before the components, and the version follows -oas
+func overlayDoc(doc *docMap, tdoc *docMap, ctxt *context) {
+	comps := "components"
+	if ctxt.oasVersion == oas20 {
+		comps = "definitions"
+	}
+	for _, k := range tdoc.keys {
+		v := tdoc.vals[k]
+		switch k {
+		case "openapi", "swagger":
+			continue
+		case "components", "definitions":
+			if tm, ok := v.(*docMap); ok {
+				if dm, ok := doc.vals[k].(*docMap); ok {
+					mergeSection(dm, tm, k == "components")
+					continue
+				}
+			}
+		}
+		doc.setBefore(comps, k, v)
+	}
+}
+
+// merge a section of the template into the same section of the document
+// components are merged one level further, so schemas etc. are added to
+func mergeSection(dm *docMap, tm *docMap, deep bool) {
+	for _, k := range tm.keys {
+		v := tm.vals[k]
+		if deep {
+			vm, ok1 := v.(*docMap)
+			dv, ok2 := dm.vals[k].(*docMap)
+			if ok1 && ok2 {
+				mergeSection(dv, vm, false)
+				continue
+			}
+		}
+		dm.set(k, v)
+	}
+}
@@ -0,0 +1,162 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// errorSchema
+// the body of an error response, expressed as an ISO 20022 rejection:
+// the status reason code, where the error is and a message, e.g.
+//
+//	{"code": "AM02", "pointer": "/CdtTrfTxInf/0/IntrBkSttlmAmt",
+//	 "xpath": "/Document/FIToFICstmrCdtTrf/CdtTrfTxInf[1]/IntrBkSttlmAmt",
+//	 "message": "amount too large"}
+//
+// the codes are those of a local copy of the ExternalStatusReason1Code list
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the component name of the error schema
+const errorSchemaName = "ErrorResponse"
+
+// a status reason code, and what it means
+type statusReason struct {
+	code string
+	name string
+}
+
+// add a code from a code list line: the code, then its name, e.g.
+//
+//	AM02 Not allowed amount
+//
+// blank lines and comments starting with # are ignored
+func addReasonLine(reasons []statusReason, line string) ([]statusReason, error) {
+	fields := strings.Fields(strings.Split(line, "#")[0])
+	if len(fields) == 0 {
+		return reasons, nil
+	}
+	code := fields[0]
+	if len(code) > 4 || regNonName.MatchString(code) {
+		return reasons, fmt.Errorf("%s is not a status reason code", code)
+	}
+	for _, r := range reasons {
+		if r.code == code {
+			return reasons, fmt.Errorf("%s is listed twice", code)
+		}
+	}
+	return append(reasons, statusReason{code, strings.Join(fields[1:], " ")}), nil
+}
+
+// the error schema is a component, unless a type already has its name
+func errorComponent(ctxt *context) bool {
+	_, isSimple := ctxt.simpleTypes[errorSchemaName]
+	_, isComplex := ctxt.complexTypes[errorSchemaName]
+	return !isSimple && !isComplex
+}
+
+// the schema of an error body: a reference to the component
+func errorSchema(ctxt *context) *docMap {
+	if !errorComponent(ctxt) {
+		return errorSchemaDef(ctxt)
+	}
+	return newDocMap().set("$ref", componentsPrefix(ctxt)+errorSchemaName)
+}
+
+// where the components are, for a reference to the error schema
+// it's only in the OAS document, so the refPrefix that the JSON schema and
+// AsyncAPI documents set for the types doesn't apply
+func componentsPrefix(ctxt *context) string {
+	if ctxt.oasVersion == oas20 {
+		return "#/definitions/"
+	}
+	return "#/components/schemas/"
+}
+
+// add the error schema to the schemas, if it's used
+func addErrorSchema(schemas *docMap, ctxt *context) {
+	if !errorUsed(ctxt) {
+		return
+	}
+	if !errorComponent(ctxt) {
+		fmt.Printf("Warning: type %s has the name of the error schema, which is written out in each response\n", errorSchemaName)
+		return
+	}
+	schemas.set(errorSchemaName, errorSchemaDef(ctxt))
+}
+
+// is the error schema used? the default paths use it, but an
+// API definition only if it has an error message
+func errorUsed(ctxt *context) bool {
+	if ctxt.api == nil || len(ctxt.api.Operations) == 0 {
+		return true
+	}
+	var used func(op apiOperation) bool
+	used = func(op apiOperation) bool {
+		found := op.Request == errorMessage
+		for _, resp := range op.Responses {
+			found = found || resp.Message == errorMessage
+		}
+		for _, cb := range op.Callbacks {
+			found = found || used(cb)
+		}
+		return found
+	}
+	for _, op := range ctxt.api.Operations {
+		if used(op) {
+			return true
+		}
+	}
+	return false
+}
+
+// the error schema
+func errorSchemaDef(ctxt *context) *docMap {
+	m := newDocMap()
+	m.set("type", "object")
+	m.set("description", "Why the request was rejected")
+	props := m.child("properties")
+
+	code := props.child("code")
+	code.set("type", "string")
+	code.set("description", "ISO 20022 status reason (ExternalStatusReason1Code)")
+	if len(ctxt.reasons) == 0 {
+		code.set("minLength", 1)
+		code.set("maxLength", 4)
+	} else {
+		codes := make([]string, 0, len(ctxt.reasons))
+		names := make(docList, 0, len(ctxt.reasons))
+		for _, r := range ctxt.reasons {
+			codes = append(codes, r.code)
+			names = append(names, r.name)
+		}
+		code.set("enum", flowStrings(codes))
+		code.set("x-enum-descriptions", names)
+	}
+
+	props.child("pointer").
+		set("type", "string").
+		set("description", "JSON pointer to the element in error, in a JSON body")
+	props.child("xpath").
+		set("type", "string").
+		set("description", "XPath of the element in error, in an XML body")
+	props.child("message").
+		set("type", "string").
+		set("description", "What is wrong")
+	m.set("required", flowStrings([]string{"code", "message"}))
+	return m
+}
@@ -0,0 +1,134 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// isoMessage
+// the ISO 20022 message identifier in the target namespace, e.g.
+// urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08 is business area pacs,
+// message functionality 008, variant 001 and version 08
+// it gives the default title, version and endpoints
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var regIsoNamespace = regexp.MustCompile("^urn:iso:std:iso:20022:tech:xsd:([a-z]{4})\\.([0-9]{3})\\.([0-9]{3})\\.([0-9]{2})$")
+
+// an ISO 20022 message identifier
+type isoMessageID struct {
+	area          string // business area, e.g. pacs
+	functionality string // message functionality, e.g. 008
+	variant       string // e.g. 001
+	version       string // e.g. 08
+}
+
+// the identifier in a namespace, or nil if it isn't an ISO 20022 one
+func parseIsoNamespace(ns string) *isoMessageID {
+	parts := regIsoNamespace.FindStringSubmatch(ns)
+	if parts == nil {
+		return nil
+	}
+	return &isoMessageID{parts[1], parts[2], parts[3], parts[4]}
+}
+
+// the identifier, e.g. pacs.008.001.08
+func (id *isoMessageID) String() string {
+	return id.area + "." + id.functionality + "." + id.variant + "." + id.version
+}
+
+// the identifier as info metadata
+func (id *isoMessageID) info() *docMap {
+	return newDocMap().
+		set("identifier", id.String()).
+		set("businessArea", id.area).
+		set("messageFunctionality", id.functionality).
+		set("variant", id.variant).
+		set("version", id.version)
+}
+
+// the identifiers of the messages, or nil unless all are ISO 20022 messages
+func isoMessages(ctxt *context) []*isoMessageID {
+	ids := make([]*isoMessageID, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		if msg.iso == nil {
+			return nil
+		}
+		ids = append(ids, msg.iso)
+	}
+	return ids
+}
+
+// the default title: the message identifiers, e.g. pacs.008.001.08
+func isoTitle(ctxt *context) string {
+	title := ""
+	for i, id := range isoMessages(ctxt) {
+		if i > 0 {
+			title += ", "
+		}
+		title += id.String()
+	}
+	return title
+}
+
+// the default version: the variant and version of the messages, e.g. 001.08,
+// if they all have the same ones
+func isoVersion(ctxt *context) string {
+	version := ""
+	for _, id := range isoMessages(ctxt) {
+		v := id.variant + "." + id.version
+		if version != "" && v != version {
+			return ""
+		}
+		version = v
+	}
+	return version
+}
+
+// add the message identifiers to the info: a mapping for one message,
+// or a list for several
+func isoInfo(info *docMap, ctxt *context) {
+	list := make(docList, 0)
+	for _, msg := range ctxt.messages {
+		if msg.iso != nil {
+			list = append(list, msg.iso.info())
+		}
+	}
+	switch len(list) {
+	case 0:
+	case 1:
+		info.set("x-iso20022-message", list[0])
+	default:
+		info.set("x-iso20022-message", list)
+	}
+}
+
+// the default package or namespace of generated code, e.g.
+// iso20022.pacs_008_001_08, iso20022 for several messages, or else from
+// the output file name
+func isoPackage(ctxt *context) string {
+	ids := isoMessages(ctxt)
+	switch {
+	case len(ids) == 1:
+		return "iso20022." + strings.Replace(ids[0].String(), ".", "_", -1)
+	case len(ids) > 1:
+		return "iso20022"
+	}
+	base := strings.TrimSuffix(ctxt.outFileBase, filepath.Ext(ctxt.outFileBase))
+	return protoName(base)
+}
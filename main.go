@@ -29,7 +29,13 @@ import (
 
 func main() {
 
-	var exf, maskf, pathf *os.File
+	// the diff command compares two versions of a message
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffMain(os.Args[2:])
+		return
+	}
+
+	var jsonf *os.File
 
 	// license notice
 	// initialise
@@ -43,17 +49,8 @@ func main() {
 		fmt.Println("under certain conditions; see COPYING.txt for details.")
 	}
 
-	// open the input file
-	fname := ctxt.inFile
-	inf, err := os.Open(fname)
-	if err != nil {
-		fmt.Printf("File %v open err %v", fname, err)
-		os.Exit(2)
-	}
-	defer inf.Close()
-
 	// open the output file
-	fname = ctxt.outFile
+	fname := ctxt.outFile
 	outf, err := os.Create(fname)
 	if err != nil {
 		fmt.Printf("File %v open err %v", fname, err)
@@ -61,67 +58,237 @@ func main() {
 	}
 	defer outf.Close()
 
-	// open the path file
-	if ctxt.pathFile != "" {
-		fname = ctxt.pathFile
-		f, err := os.Create(fname)
+	// open the template file
+	if ctxt.templateFile != "" {
+		b, err := ioutil.ReadFile(ctxt.templateFile)
 		if err != nil {
-			fmt.Printf("File %v open err %v", fname, err)
+			fmt.Printf("File %v read err %v", ctxt.templateFile, err)
 			os.Exit(2)
 		}
-		pathf = f
+		ctxt.hdrTemplate = string(b)
 	}
-	defer pathf.Close()
 
-	// open the mask file
-	if ctxt.maskFile != "" {
-		ctxt.mask = true
-		fname := ctxt.maskFile
-		maskf, err := os.Open(fname)
+	// read the API definition file
+	if ctxt.apiFile != "" {
+		b, err := ioutil.ReadFile(ctxt.apiFile)
+		if err != nil {
+			fmt.Printf("File %v read err %v", ctxt.apiFile, err)
+			os.Exit(2)
+		}
+		ctxt.api, err = parseApiDef(b)
+		if err != nil {
+			fmt.Printf("API definition %v parse err %v\n", ctxt.apiFile, err)
+			os.Exit(2)
+		}
+	}
+
+	// read the abbreviations dictionary
+	if ctxt.abbrevFile != "" {
+		fname := ctxt.abbrevFile
+		f, err := os.Open(fname)
 		if err != nil {
 			fmt.Printf("File %v open err %v", fname, err)
 			os.Exit(2)
 		}
-		scanner := bufio.NewScanner(maskf)
-		for scanner.Scan() {
-			s := scanner.Text()
-			ctxt.maskLines = append(ctxt.maskLines, strings.TrimSpace(strings.Split(s, "#")[0]))
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if err := ctxt.abbrevs.addLine(scanner.Text()); err != nil {
+				fmt.Printf("File %v line %d: %v\n", fname, line, err)
+				os.Exit(2)
+			}
 		}
 		if scanner.Err() != nil {
 			fmt.Printf("File %v scan err %v", fname, scanner.Err())
 			os.Exit(2)
 		}
-		// fmt.Printf("File %v scanned OK - %v lines\n", fname, len(ctxt.maskLines))
+		f.Close()
 	}
-	defer maskf.Close()
 
-	// open the template file
-	if ctxt.templateFile != "" {
-		b, err := ioutil.ReadFile(ctxt.templateFile)
+	// read the status reason codes
+	if ctxt.reasonFile != "" {
+		fname := ctxt.reasonFile
+		f, err := os.Open(fname)
 		if err != nil {
-			fmt.Printf("File %v read err %v", ctxt.templateFile, err)
+			fmt.Printf("File %v open err %v", fname, err)
 			os.Exit(2)
 		}
-		ctxt.hdrTemplate = string(b)
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if ctxt.reasons, err = addReasonLine(ctxt.reasons, scanner.Text()); err != nil {
+				fmt.Printf("File %v line %d: %v\n", fname, line, err)
+				os.Exit(2)
+			}
+		}
+		if scanner.Err() != nil {
+			fmt.Printf("File %v scan err %v", fname, scanner.Err())
+			os.Exit(2)
+		}
+		f.Close()
 	}
 
-	// open the example file
-	if ctxt.exFile != "" {
-		fname := ctxt.exFile
+	// open the JSON schema file
+	if ctxt.jsonFile != "" {
+		fname := ctxt.jsonFile
 		f, err := os.Create(fname)
 		if err != nil {
 			fmt.Printf("File %v open err %v", fname, err)
 			os.Exit(2)
 		}
-		exf = f
-		// fmt.Printf("File %v open OK\n", fname)
+		jsonf = f
+	}
+	defer jsonf.Close()
+
+	// read each message, and combine their types
+	for _, msg := range ctxt.messages {
+		mctxt := readMessage(msg, &ctxt)
+		mergeMessage(&ctxt, &mctxt, msg)
 	}
-	defer exf.Close()
 
-	parseXml(inf, &ctxt)
-	tagInclude(pathf, &ctxt)
+	nameProperties(&ctxt)
 	writeYaml(outf, &ctxt)
-	if ctxt.exFile != "" {
-		writeExample(exf, &ctxt)
+	for _, msg := range ctxt.messages {
+		if msg.exFile != "" {
+			fname := msg.exFile
+			exf, err := os.Create(fname)
+			if err != nil {
+				fmt.Printf("File %v open err %v", fname, err)
+				os.Exit(2)
+			}
+			writeExample(exf, msg, &ctxt)
+			exf.Close()
+		}
+	}
+	if ctxt.jsonFile != "" {
+		writeJsonSchema(jsonf, &ctxt)
+	}
+	if ctxt.asyncFile != "" {
+		fname := ctxt.asyncFile
+		asyncf, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeAsyncApi(asyncf, &ctxt)
+		asyncf.Close()
+	}
+	if ctxt.protoFile != "" {
+		fname := ctxt.protoFile
+		protof, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeProto(protof, &ctxt)
+		protof.Close()
+	}
+	if ctxt.avroFile != "" {
+		fname := ctxt.avroFile
+		avrof, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeAvro(avrof, &ctxt)
+		avrof.Close()
+	}
+	if ctxt.goFile != "" {
+		fname := ctxt.goFile
+		gof, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeGo(gof, &ctxt)
+		gof.Close()
+	}
+	if ctxt.tsFile != "" {
+		fname := ctxt.tsFile
+		tsf, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeTs(tsf, &ctxt)
+		tsf.Close()
+	}
+	if ctxt.sqlFile != "" {
+		fname := ctxt.sqlFile
+		sqlf, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeSql(sqlf, &ctxt)
+		sqlf.Close()
+	}
+	if ctxt.graphqlFile != "" {
+		fname := ctxt.graphqlFile
+		graphqlf, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		writeGraphql(graphqlf, &ctxt)
+		graphqlf.Close()
 	}
 }
+
+// read the XSD of a message, and tag the elements to include
+func readMessage(msg *message, ctxt *context) context {
+	var pathf *os.File
+	mctxt := newMessageContext(ctxt)
+
+	// open the input file
+	fname := msg.inFile
+	inf, err := os.Open(fname)
+	if err != nil {
+		fmt.Printf("File %v open err %v", fname, err)
+		os.Exit(2)
+	}
+	defer inf.Close()
+
+	// open the path file
+	if msg.pathFile != "" {
+		fname = msg.pathFile
+		f, err := os.Create(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		pathf = f
+	}
+	defer pathf.Close()
+
+	// open the mask file
+	if msg.maskFile != "" {
+		mctxt.mask = true
+		fname := msg.maskFile
+		maskf, err := os.Open(fname)
+		if err != nil {
+			fmt.Printf("File %v open err %v", fname, err)
+			os.Exit(2)
+		}
+		defer maskf.Close()
+		scanner := bufio.NewScanner(maskf)
+		for scanner.Scan() {
+			s := strings.SplitN(scanner.Text(), "#", 2)
+			path := strings.TrimSpace(s[0])
+			mctxt.maskLines = append(mctxt.maskLines, path)
+			if path != "" {
+				line := maskLine{path: path}
+				if len(s) > 1 {
+					line.comment = strings.TrimSpace(s[1])
+				}
+				msg.mask = append(msg.mask, line)
+			}
+		}
+		if scanner.Err() != nil {
+			fmt.Printf("File %v scan err %v", fname, scanner.Err())
+			os.Exit(2)
+		}
+	}
+
+	parseXml(inf, &mctxt)
+	tagInclude(pathf, &mctxt)
+	return mctxt
+}
@@ -0,0 +1,253 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// merge
+// combine the types of several messages into one set of definitions
+// identical types (e.g. Max35Text) are shared; a type whose name clashes
+// with a different definition is renamed with the message as a suffix,
+// e.g. Document_pacs_004_001_09
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var regNonName = regexp.MustCompile("[^A-Za-z0-9]+") // characters not allowed in a name suffix
+
+// a context for reading one message, with the options of the main context
+func newMessageContext(ctxt *context) context {
+	m := *ctxt
+	m.simpleTypes = make(map[string]*simpleType)
+	m.complexTypes = make(map[string]*complexType)
+	m.mask = false
+	m.maskLines = nil
+	m.root = nil
+	m.namespace = ""
+	m.smplType = nil
+	m.cplxType = nil
+	m.elem = nil
+	return m
+}
+
+// the suffix for the clashing types of a message: its file name, e.g.
+// pacs.004.001.09.xsd gives pacs_004_001_09
+func messageSuffix(msg *message) string {
+	base := strings.TrimSuffix(msg.inFileBase, filepath.Ext(msg.inFileBase))
+	return strings.Trim(regNonName.ReplaceAllString(base, "_"), "_")
+}
+
+// merge the types of a message (read into mctxt) into the main context
+func mergeMessage(ctxt *context, mctxt *context, msg *message) {
+	// the types that clash ...
+	renames := make(map[string]string)
+	suffix := messageSuffix(msg)
+	root := mctxt.root.etype
+	for name, simple := range mctxt.simpleTypes {
+		if !sameAsMerged(name, simple, root, ctxt) {
+			renames[name] = name + "_" + suffix
+		}
+	}
+	for name, cmplx := range mctxt.complexTypes {
+		if !sameAsMerged(name, cmplx, root, ctxt) {
+			renames[name] = name + "_" + suffix
+		}
+	}
+	// ... and the types that refer to them, which now differ too
+	for changed := true; changed; {
+		changed = false
+		for name, cmplx := range mctxt.complexTypes {
+			if _, ok := renames[name]; !ok && isMerged(name, ctxt) && refersTo(cmplx, renames) {
+				renames[name] = name + "_" + suffix
+				changed = true
+			}
+		}
+		for name, simple := range mctxt.simpleTypes {
+			if _, ok := renames[name]; !ok && isMerged(name, ctxt) && refersTo(simple, renames) {
+				renames[name] = name + "_" + suffix
+				changed = true
+			}
+		}
+	}
+	names := make([]string, 0, len(renames))
+	for name := range renames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Type %s in %s differs from an earlier message, renamed %s\n", name, msg.inFileBase, renames[name])
+	}
+
+	// add the types, unless the same type is already there
+	for _, simple := range mctxt.simpleTypes {
+		renameRefs(simple, renames)
+		if old, ok := ctxt.simpleTypes[simple.name]; !ok || !isOutput(old.name, old.include, "", ctxt) {
+			ctxt.simpleTypes[simple.name] = simple
+		}
+	}
+	for _, cmplx := range mctxt.complexTypes {
+		renameRefs(cmplx, renames)
+		if old, ok := ctxt.complexTypes[cmplx.name]; !ok || !isOutput(old.name, old.include, "", ctxt) {
+			ctxt.complexTypes[cmplx.name] = cmplx
+		}
+	}
+
+	msg.root = mctxt.root
+	msg.namespace = mctxt.namespace
+	msg.iso = parseIsoNamespace(msg.namespace)
+	if rename, ok := renames[msg.root.etype]; ok {
+		msg.root.etype = rename
+	}
+	// the root element and namespace are those of the first message
+	// types are only in one namespace if all the messages are
+	if ctxt.root == nil {
+		ctxt.root = msg.root
+		ctxt.namespace = msg.namespace
+	} else if ctxt.namespace != msg.namespace {
+		ctxt.namespace = ""
+	}
+}
+
+// is there a type of this name already?
+func isMerged(name string, ctxt *context) bool {
+	_, isSimple := ctxt.simpleTypes[name]
+	_, isComplex := ctxt.complexTypes[name]
+	return isSimple || isComplex
+}
+
+// is a type output? it is if it's included, or the type of a root element
+// root is the root element type of the message being merged
+func isOutput(name string, include bool, root string, ctxt *context) bool {
+	if include || name == root {
+		return true
+	}
+	for _, msg := range ctxt.messages {
+		if msg.root != nil && msg.root.etype == name {
+			return true
+		}
+	}
+	return false
+}
+
+// is a type the same as the one of that name already merged (if any)?
+// a type that isn't output can't clash
+func sameAsMerged(name string, t interface{}, root string, ctxt *context) bool {
+	var old interface{}
+	switch val := t.(type) {
+	case *simpleType:
+		o, ok := ctxt.simpleTypes[name]
+		if !ok {
+			return !isMerged(name, ctxt)
+		}
+		if !isOutput(name, o.include, "", ctxt) || !isOutput(name, val.include, root, ctxt) {
+			return true
+		}
+		old = o
+	case *complexType:
+		o, ok := ctxt.complexTypes[name]
+		if !ok {
+			return !isMerged(name, ctxt)
+		}
+		if !isOutput(name, o.include, "", ctxt) || !isOutput(name, val.include, root, ctxt) {
+			return true
+		}
+		old = o
+	}
+	return reflect.DeepEqual(withoutDocs(old), withoutDocs(t))
+}
+
+// a copy of a type without its documentation, which doesn't make it differ
+func withoutDocs(t interface{}) interface{} {
+	switch val := t.(type) {
+	case *simpleType:
+		n := *val
+		n.doc = ""
+		return &n
+	case *complexType:
+		n := *val
+		n.doc = ""
+		n.elems = make([]*element, 0, len(val.elems))
+		for _, el := range val.elems {
+			e := *el
+			e.doc = ""
+			n.elems = append(n.elems, &e)
+		}
+		return &n
+	}
+	return t
+}
+
+// does a type refer to any of the renamed types?
+func refersTo(t interface{}, renames map[string]string) bool {
+	found := false
+	check := func(name string) {
+		_, ok := renames[name]
+		found = found || ok
+	}
+	switch val := t.(type) {
+	case *simpleType:
+		for _, attr := range val.attrs {
+			check(attr.atype)
+		}
+	case *complexType:
+		for _, attr := range val.attrs {
+			check(attr.atype)
+		}
+		for _, el := range val.elems {
+			check(el.etype)
+		}
+		if val.simpleBase != nil {
+			for _, attr := range val.simpleBase.attrs {
+				check(attr.atype)
+			}
+		}
+	}
+	return found
+}
+
+// rename a type, and its references to the renamed types
+func renameRefs(t interface{}, renames map[string]string) {
+	rename := func(name *string) {
+		if r, ok := renames[*name]; ok {
+			*name = r
+		}
+	}
+	renameAttrs := func(attrs []attribute) {
+		for i := range attrs {
+			rename(&attrs[i].atype)
+		}
+	}
+	switch val := t.(type) {
+	case *simpleType:
+		rename(&val.name)
+		renameAttrs(val.attrs)
+	case *complexType:
+		rename(&val.name)
+		renameAttrs(val.attrs)
+		for _, el := range val.elems {
+			rename(&el.etype)
+		}
+		if val.simpleBase != nil {
+			rename(&val.simpleBase.name)
+			renameAttrs(val.simpleBase.attrs)
+		}
+	}
+}
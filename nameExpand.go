@@ -0,0 +1,253 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// nameExpand
+// expand abbreviated ISO 20022 tags into full business names
+// e.g. IntrBkSttlmAmt becomes InterbankSettlementAmount
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// abbreviations and their expansions
+// an entry can be one word of a tag, several words, or a whole tag
+type abbreviations map[string]string
+
+// the standard ISO 20022 abbreviations
+// acronyms such as FI, BIC and UETR are left alone
+var isoAbbrevs = abbreviations{
+	"Acct":     "Account",
+	"Accptnc":  "Acceptance",
+	"Actv":     "Active",
+	"Addtl":    "Additional",
+	"Adr":      "Address",
+	"Agt":      "Agent",
+	"Amt":      "Amount",
+	"Ar":       "Area",
+	"Assgne":   "Assignee",
+	"Assgnr":   "Assignor",
+	"Authstn":  "Authorisation",
+	"Bal":      "Balance",
+	"Bldg":     "Building",
+	"Bk":       "Bank",
+	"Bookg":    "Booking",
+	"Br":       "Bearer",
+	"Brnch":    "Branch",
+	"Btch":     "Batch",
+	"Bx":       "Box",
+	"Ccy":      "Currency",
+	"Cd":       "Code",
+	"Cdt":      "Credit",
+	"Cdtr":     "Creditor",
+	"Cert":     "Certificate",
+	"Chanl":    "Channel",
+	"Chq":      "Cheque",
+	"Chrg":     "Charge",
+	"Chrgs":    "Charges",
+	"Clr":      "Clearing",
+	"Cmplc":    "Compliance",
+	"Cmpnt":    "Component",
+	"Cntr":     "Counter",
+	"Cnfrmtn":  "Confirmation",
+	"Cpy":      "Copy",
+	"Cre":      "Creation",
+	"Cstmr":    "Customer",
+	"Ctct":     "Contact",
+	"Ctgy":     "Category",
+	"Ctrl":     "Control",
+	"Ctry":     "Country",
+	"Cxl":      "Cancellation",
+	"Dbt":      "Debit",
+	"Dbtr":     "Debtor",
+	"Dept":     "Department",
+	"Desc":     "Description",
+	"Dlvry":    "Delivery",
+	"Doc":      "Document",
+	"Dstrct":   "District",
+	"Dt":       "Date",
+	"Dtls":     "Details",
+	"DtTm":     "DateTime",
+	"Dtld":     "Detailed",
+	"Dy":       "Day",
+	"Eqvt":     "Equivalent",
+	"Fin":      "Financial",
+	"Flr":      "Floor",
+	"Frmt":     "Format",
+	"Fr":       "From",
+	"Fwd":      "Forward",
+	"Grp":      "Group",
+	"Hdr":      "Header",
+	"Id":       "Identification",
+	"Inf":      "Information",
+	"Instd":    "Instructed",
+	"Instg":    "Instructing",
+	"Instn":    "Institution",
+	"Instr":    "Instruction",
+	"Instrm":   "Instrument",
+	"IntrBk":   "Interbank",
+	"Intrmy":   "Intermediary",
+	"Invstgtn": "Investigation",
+	"Issr":     "Issuer",
+	"Lang":     "Language",
+	"Lcl":      "Local",
+	"Lctn":     "Location",
+	"Lgl":      "Legal",
+	"Lvl":      "Level",
+	"Mbr":      "Member",
+	"Mmb":      "Member",
+	"Mob":      "Mobile",
+	"Msg":      "Message",
+	"Mtd":      "Method",
+	"Nb":       "Number",
+	"Nm":       "Name",
+	"Ntfctn":   "Notification",
+	"Ntry":     "Entry",
+	"Org":      "Organisation",
+	"Orgnl":    "Original",
+	"Othr":     "Other",
+	"Pmt":      "Payment",
+	"Phne":     "Phone",
+	"Prd":      "Period",
+	"Prfrd":    "Preferred",
+	"Prtry":    "Proprietary",
+	"Prty":     "Priority",
+	"Prvs":     "Previous",
+	"Prvt":     "Private",
+	"Prxy":     "Proxy",
+	"Pst":      "Post",
+	"Pstl":     "Postal",
+	"Purp":     "Purpose",
+	"Qty":      "Quantity",
+	"Rcvr":     "Receiver",
+	"Ref":      "Reference",
+	"Refs":     "References",
+	"Reqd":     "Requested",
+	"Rgltry":   "Regulatory",
+	"Rltd":     "Related",
+	"Rm":       "Room",
+	"Rmt":      "Remittance",
+	"Rptg":     "Reporting",
+	"Rsn":      "Reason",
+	"Rspn":     "Response",
+	"Rtr":      "Return",
+	"Rtrd":     "Returned",
+	"Schme":    "Scheme",
+	"Sctn":     "Section",
+	"Sndr":     "Sender",
+	"Spcfd":    "Specified",
+	"Splmtry":  "Supplementary",
+	"Strd":     "Structured",
+	"Strt":     "Street",
+	"Sts":      "Status",
+	"Sttld":    "Settled",
+	"Sttlm":    "Settlement",
+	"Svc":      "Service",
+	"Sys":      "System",
+	"Tm":       "Time",
+	"Tp":       "Type",
+	"Ttl":      "Total",
+	"Trf":      "Transfer",
+	"Twn":      "Town",
+	"Tx":       "Transaction",
+	"Txs":      "Transactions",
+	"Ultmt":    "Ultimate",
+	"Ustrd":    "Unstructured",
+	"Vldtn":    "Validation",
+	"Vrsn":     "Version",
+	"Xchg":     "Exchange",
+}
+
+// add an entry from a dictionary file line: the abbreviation, then the full name
+// the words of the full name are run together, e.g.
+//
+//	CdtrAgt Creditor Agent
+//
+// blank lines and comments starting with # are ignored
+func (a abbreviations) addLine(line string) error {
+	fields := strings.Fields(strings.Split(line, "#")[0])
+	switch len(fields) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("no full name for %s", fields[0])
+	}
+	full := ""
+	for _, word := range fields[1:] {
+		r := []rune(word)
+		full += string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	a[fields[0]] = full
+	return nil
+}
+
+// expand a tag, word by word
+// the longest run of words found in the dictionary wins, and
+// words that aren't found are kept as they are
+func (a abbreviations) expand(tag string) string {
+	words := tagWords(tag)
+	name := ""
+	for i := 0; i < len(words); {
+		j := len(words)
+		for ; j > i+1; j-- {
+			if _, ok := a[strings.Join(words[i:j], "")]; ok {
+				break
+			}
+		}
+		abbr := strings.Join(words[i:j], "")
+		if full, ok := a[abbr]; ok {
+			name += full
+		} else {
+			name += abbr
+		}
+		i = j
+	}
+	return name
+}
+
+// split a tag into words, each starting with an uppercase letter
+// a run of uppercase letters is an acronym, except that the last
+// starts a word if it is followed by lowercase, e.g.
+//
+//	FIToFICstmrCdtTrf is FI To FI Cstmr Cdt Trf
+func tagWords(tag string) []string {
+	r := []rune(tag)
+	words := make([]string, 0)
+	start := 0
+	for i := 1; i < len(r); i++ {
+		if !unicode.IsUpper(r[i]) {
+			continue
+		}
+		prev := r[i-1]
+		if !unicode.IsUpper(prev) || (i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(r[start:]))
+}
+
+// the built-in abbreviations, with the entries of a dictionary file on top
+func newAbbreviations() abbreviations {
+	a := make(abbreviations)
+	for k, v := range isoAbbrevs {
+		a[k] = v
+	}
+	return a
+}
@@ -0,0 +1,58 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// naming
+// naming strategies for JSON properties, e.g. lowerCamelCase or snake_case
+
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// the tag as it is
+const namingKeep = "keep"
+
+// the built-in strategies, selected with -naming
+// each works on the words of the name, so acronyms stay whole, e.g.
+// FIToFICstmrCdtTrf is fiToFICstmrCdtTrf, fi_to_fi_cstmr_cdt_trf or fi-to-fi-cstmr-cdt-trf
+var namingStrategies = map[string]renamer{
+	namingKeep: func(name string) string {
+		return name
+	},
+	"lowerCamel": func(name string) string {
+		words := tagWords(name)
+		words[0] = strings.ToLower(words[0])
+		return strings.Join(words, "")
+	},
+	"snake": func(name string) string {
+		return strings.ToLower(strings.Join(tagWords(name), "_"))
+	},
+	"kebab": func(name string) string {
+		return strings.ToLower(strings.Join(tagWords(name), "-"))
+	},
+}
+
+// the names of the strategies, for the usage message
+func namingNames() []string {
+	names := make([]string, 0, len(namingStrategies))
+	for name := range namingStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 // parse the XML file handle and populate the context
@@ -44,7 +45,9 @@ func parseXml(f io.Reader, ctxt *context) {
 		case xml.EndElement:
 			endElement(&el, ctxt)
 		case xml.CharData:
-			// fmt.Printf("charData: %v\n", el)
+			if ctxt.inDoc {
+				ctxt.docText += string(el)
+			}
 		case xml.Comment:
 			// fmt.Printf("comment: %v\n", el)
 		case xml.Directive:
@@ -79,6 +82,8 @@ func startElement(el *xml.StartElement, ctxt *context) {
 				elem.etype = value
 			case "minOccurs":
 				elem.minOccurs, _ = strconv.Atoi(value)
+			case "nillable":
+				elem.nillable = (value == "true")
 			case "maxOccurs":
 				if value == "unbounded" {
 					elem.maxOccurs = 9999999
@@ -144,8 +149,10 @@ func startElement(el *xml.StartElement, ctxt *context) {
 			case isSimple:
 				// We are going to change this to a simple type
 				smplName := ctxt.cplxType.name
+				doc := ctxt.cplxType.doc
 				ctxt.cplxType = nil
 				ctxt.smplType = simpleBase.clone(&smplName)
+				ctxt.smplType.doc = doc
 				//				ctxt.cplxType.simpleBase = &simpleBase
 			case isComplex:
 				// deep copy of the base type, then we can over-write / add to elements
@@ -186,7 +193,13 @@ func startElement(el *xml.StartElement, ctxt *context) {
 		break
 	case "any":
 		ctxt.cplxType.anyFlag = true
-	case "schema": // do nothing
+	case "schema":
+		ctxt.namespace = attrs["targetNamespace"]
+	case "annotation", "appinfo":
+		break
+	case "documentation":
+		ctxt.inDoc = true
+		ctxt.docText = ""
 	default:
 		fmt.Printf("startElement: %v\n", el.Name.Local)
 		for _, attr := range el.Attr {
@@ -218,7 +231,12 @@ func endElement(el *xml.EndElement, ctxt *context) {
 	case "extension":
 	case "any":
 	case "schema":
+	case "annotation":
+	case "appinfo":
 		//all the above do nothing
+	case "documentation":
+		ctxt.inDoc = false
+		addDoc(strings.Join(strings.Fields(ctxt.docText), " "), ctxt)
 	case "element":
 		ctxt.elem = nil // force an error if assignment attempted
 	case "simpleType":
@@ -238,3 +256,22 @@ func endElement(el *xml.EndElement, ctxt *context) {
 		fmt.Printf("Unclassified endElement: %v\n", el.Name.Local)
 	}
 }
+
+// keep documentation with what it documents: the element, or else the type
+func addDoc(doc string, ctxt *context) {
+	add := func(to *string) {
+		if *to != "" {
+			doc = *to + " " + doc
+		}
+		*to = doc
+	}
+	switch {
+	case doc == "":
+	case ctxt.elem != nil:
+		add(&ctxt.elem.doc)
+	case ctxt.smplType != nil:
+		add(&ctxt.smplType.doc)
+	case ctxt.cplxType != nil:
+		add(&ctxt.cplxType.doc)
+	}
+}
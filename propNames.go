@@ -0,0 +1,198 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// propNames
+// the JSON property names for XML tags
+// each tag passes through the renaming steps in turn, and the names
+// are worked out once so the spec, the examples and the schema agree
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a renaming step
+type renamer func(tag string) string
+
+var regupr = regexp.MustCompile("[a-z]") // compiled regexp to find lowercase
+
+// convert names that are all uppercase to camelcase
+// works around the Swagger uppercase bug
+func fixup(name string) string {
+	if regupr.FindStringIndex(name) == nil {
+		name = name[0:1] + strings.ToLower(name[1:])
+	}
+	return name
+}
+
+// the renaming steps, in order
+func renamers(ctxt *context) []renamer {
+	steps := make([]renamer, 0)
+	if ctxt.expand {
+		steps = append(steps, ctxt.abbrevs.expand)
+	}
+	if ctxt.naming != namingKeep {
+		steps = append(steps, namingStrategies[ctxt.naming])
+	}
+	if ctxt.fixUppercase {
+		steps = append(steps, fixup)
+	}
+	return steps
+}
+
+// a property to be named: its key in the names of its type,
+// and the name it has before renaming
+type propTag struct {
+	key  string
+	orig string
+}
+
+// the key for an attribute, so it can't clash with an element of the same name
+func attrTag(name string) string {
+	return "@" + name
+}
+
+// the key for an attribute flattened into the parent of its element
+func flatTag(elem string, attr string) string {
+	return elem + "@" + attr
+}
+
+// work out the property names of every type
+func nameProperties(ctxt *context) {
+	steps := renamers(ctxt)
+	ctxt.propNames = make(map[string]map[string]string)
+
+	owners := make([]string, 0)
+	tags := make(map[string][]propTag)
+	reserved := make(map[string]string)
+	for _, simple := range ctxt.simpleTypes {
+		owners = append(owners, simple.name)
+		// the attributes sit next to the property holding the text
+		if len(simple.attrs) > 0 && !ctxt.attrMap.flatten() {
+			reserved[simple.name] = ctxt.attrMap.valueName()
+		}
+		for _, attr := range simple.attrs {
+			tags[simple.name] = append(tags[simple.name], propTag{attrTag(attr.name), attr.name})
+		}
+	}
+	for _, cmplx := range ctxt.complexTypes {
+		owners = append(owners, cmplx.name)
+		for _, attr := range cmplx.attrs {
+			tags[cmplx.name] = append(tags[cmplx.name], propTag{attrTag(attr.name), attr.name})
+		}
+		for _, el := range cmplx.elems {
+			tags[cmplx.name] = append(tags[cmplx.name], propTag{el.name, el.name})
+			if simple, ok := flatAttrType(el, ctxt); ok {
+				for _, attr := range simple.attrs {
+					tags[cmplx.name] = append(tags[cmplx.name],
+						propTag{flatTag(el.name, attr.name), ctxt.attrMap.attrName(el.name, attr.name)})
+				}
+			}
+		}
+	}
+	// sorted so any warnings come out in the same order each time
+	sort.Strings(owners)
+	for _, owner := range owners {
+		nameOwner(owner, tags[owner], reserved[owner], steps, ctxt)
+	}
+}
+
+// name the properties of one type
+// attribute names include their prefix, so with -attrs plain an attribute
+// can clash with an element, or with the value (reserved)
+// if two tags end up with the same name, the later one gets a number
+func nameOwner(owner string, tags []propTag, reserved string, steps []renamer, ctxt *context) {
+	names := make(map[string]string)
+	used := make(map[string]string)
+	if reserved != "" {
+		used[reserved] = reserved
+	}
+	for _, tag := range tags {
+		name := tag.orig
+		for _, step := range steps {
+			name = step(name)
+		}
+		prop := propOf(tag, name, ctxt)
+		if prev, ok := used[prop]; ok && prev != tag.key {
+			base, clash := name, prop
+			for n := 2; used[prop] != ""; n++ {
+				name = base + "_" + strconv.Itoa(n)
+				prop = propOf(tag, name, ctxt)
+			}
+			fmt.Printf("Warning: in %s, %s and %s are both named %s, naming %s %s\n",
+				owner, prev, tag.key, clash, tag.key, prop)
+		}
+		used[prop] = tag.key
+		names[tag.key] = name
+	}
+	ctxt.propNames[owner] = names
+}
+
+// the property for a renamed tag: an attribute's has its prefix
+func propOf(tag propTag, name string, ctxt *context) string {
+	if tag.key == attrTag(tag.orig) {
+		return ctxt.attrMap.attrName("", name)
+	}
+	return name
+}
+
+// the property name for an element of a type
+func propName(owner string, tag string, ctxt *context) string {
+	if name, ok := ctxt.propNames[owner][tag]; ok {
+		return name
+	}
+	return tag
+}
+
+// the property name for an attribute of a type, in the object
+// holding the element text, e.g. @Ccy
+func attrPropName(owner string, attr string, ctxt *context) string {
+	name, ok := ctxt.propNames[owner][attrTag(attr)]
+	if !ok {
+		name = attr
+	}
+	return ctxt.attrMap.attrName("", name)
+}
+
+// the property name for an attribute flattened into the parent
+// of its element, e.g. IntrBkSttlmAmtCcy
+func flatPropName(parent string, elem string, attr string, ctxt *context) string {
+	if name, ok := ctxt.propNames[parent][flatTag(elem, attr)]; ok {
+		return name
+	}
+	return ctxt.attrMap.attrName(elem, attr)
+}
+
+// keep the original tag of a renamed property, so converters can map back
+// expansion keeps it in x-xml-tag, and a naming strategy in x-xml-name
+func xmlTag(m *docMap, tag string, name string, ctxt *context) *docMap {
+	if name == tag || (!ctxt.expand && ctxt.naming == namingKeep) {
+		return m
+	}
+	m = refSiblings(m, ctxt)
+	if ctxt.expand {
+		m.set("x-xml-tag", tag)
+	}
+	if ctxt.naming != namingKeep {
+		m.set("x-xml-name", tag)
+	}
+	return m
+}
@@ -26,6 +26,7 @@ type named interface {
 
 // anything that has attributes
 type attributed interface {
+	named
 	getAttrs() []attribute
 }
 
@@ -35,7 +36,9 @@ type element struct {
 	etype     string
 	minOccurs int
 	maxOccurs int
-	include   bool // if using mask
+	nillable  bool
+	include   bool   // if using mask
+	doc       string // xs:documentation
 }
 
 // any attribute
@@ -65,7 +68,8 @@ type simpleType struct {
 	maxLength      int
 	whiteSpace     string // preserve | replace | collapse
 	pattern        string
-	include        bool // if using mask
+	include        bool   // if using mask
+	doc            string // xs:documentation
 }
 
 // definition of a complex type
@@ -75,39 +79,89 @@ type complexType struct {
 	etype      string // sequence | choice
 	elems      []*element
 	simpleBase *simpleType
-	anyFlag    bool //does the type allow "any" extension?
-	include    bool // if using mask
+	anyFlag    bool   //does the type allow "any" extension?
+	include    bool   // if using mask
+	doc        string // xs:documentation
+}
+
+// one message: an XSD and the files that go with it
+type message struct {
+	inFile     string
+	inFileBase string // base part of path
+	maskFile   string
+	pathFile   string
+	exFile     string
+	endpoint   string
+	mask       []maskLine
+	iso        *isoMessageID // from the namespace, if it's an ISO 20022 one
+	root       *element      // the root element, whose type is renamed if it clashes
+	namespace  string        // targetNamespace
+}
+
+// a line of a mask file: a path, and the comment after it (if any)
+type maskLine struct {
+	path    string
+	comment string
 }
 
 // data being worked on
 type context struct {
-	inFile       string
-	outFile      string
-	exFile       string
-	maskFile     string
-	pathFile     string
-	templateFile string
-	inFileBase   string // base part of path
-	outFileBase  string
-	exFileBase   string
-	maskFileBase string
-	pathFileBase string
-	printLicense bool
-	fixUppercase bool
-	all          bool
-	mask         bool
-	maskLines    []string
-	servers      string
-	endpoint     string
-	title        string
-	hdrTemplate  string
-	smplType     *simpleType
-	cplxType     *complexType
-	elem         *element
+	messages      []*message
+	outFile       string
+	templateFile  string
+	outFileBase   string // base part of path
+	printLicense  bool
+	fixUppercase  bool
+	all           bool
+	decimalMode   string // number | string
+	format        string // yaml | json
+	oasVersion    string // 2.0 | 3.0 | 3.1
+	refPrefix     string // where schemas are referenced, if not the OAS default
+	jsonFile      string
+	jsonDraft     string // 07 | 2020-12
+	xml           bool   // describe the XML wire format too
+	xmlPrefix     string
+	attrMap       attrMapping // JSON convention for attributes
+	expand        bool        // expand ISO 20022 abbreviations in property names
+	abbrevFile    string
+	abbrevs       abbreviations
+	naming        string // naming strategy for properties
+	reasonFile    string
+	callback      string // the message sent back as a callback of the default paths
+	callbackURL   string // the runtime expression for its URL
+	webhook       string // the message sent as a webhook
+	asyncFile     string
+	asyncVersion  string // 2.6.0 | 3.0.0
+	protoFile     string
+	protoNumFile  string // proto field numbers, kept between runs
+	protoPackage  string
+	avroFile      string
+	avroNamespace string
+	goFile        string
+	goPackage     string
+	tsFile        string
+	sqlFile       string
+	sqlDialect    string // postgres | sqlite
+	graphqlFile   string
+	reasons       []statusReason // the status reason codes for error responses
+	mask          bool
+	maskLines     []string
+	servers       string
+	title         string
+	hdrTemplate   string
+	apiFile       string
+	api           *apiDefinition
+	smplType      *simpleType
+	cplxType      *complexType
+	elem          *element
+	inDoc         bool   // in xs:documentation
+	docText       string // the documentation so far
 	// the dictionary
+	namespace    string // targetNamespace
 	root         *element
 	simpleTypes  map[string]*simpleType
 	complexTypes map[string]*complexType
+	propNames    map[string]map[string]string // type => tag => property name
 }
 
 // initialise the context
@@ -115,6 +169,7 @@ func newContext() context {
 	c := context{}
 	c.simpleTypes = make(map[string]*simpleType)
 	c.complexTypes = make(map[string]*complexType)
+	c.abbrevs = newAbbreviations()
 	return c
 }
 
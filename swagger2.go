@@ -0,0 +1,122 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// swagger2
+// Swagger 2.0 output, for gateways that can't import OAS 3
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// split the server URLs into host, basePath and schemes
+// Swagger 2.0 only has one host and basePath, so they come from the first server
+func serverParts(servers []string) (string, string, []string) {
+	host, basePath := "", ""
+	schemes := make([]string, 0)
+	for i, s := range servers {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			fmt.Printf("Server %s ignored: not a valid URL\n", s)
+			continue
+		}
+		if i == 0 || host == "" {
+			host, basePath = u.Host, u.Path
+		} else if u.Host != host || u.Path != basePath {
+			fmt.Printf("Server %s ignored: Swagger 2.0 allows only one host and basePath\n", s)
+			continue
+		}
+		found := false
+		for _, scheme := range schemes {
+			found = found || scheme == u.Scheme
+		}
+		if !found {
+			schemes = append(schemes, u.Scheme)
+		}
+	}
+	return host, basePath, schemes
+}
+
+// the default headers for Swagger 2.0, used if no template is given
+func swaggerHeader(title string, servers []string, ctxt *context) *docMap {
+	doc := swaggerInfo(title, docVersion(ctxt), servers, ctxt)
+	if ctxt.callback != "" {
+		fmt.Printf("Warning: -callback ignored, Swagger 2.0 has no callbacks\n")
+	}
+	paths := doc.child("paths")
+	for _, msg := range ctxt.messages {
+		if serverMessage(msg, ctxt) {
+			continue
+		}
+		if put := messagePath(paths, msg, ctxt); put != nil {
+			swaggerOperation(put, msg, ctxt)
+		}
+	}
+	return doc
+}
+
+// the start of a Swagger 2.0 document: version, info, host and media types
+func swaggerInfo(title string, version string, servers []string, ctxt *context) *docMap {
+	doc := newDocMap()
+	doc.set("swagger", oas20)
+	info := doc.child("info").
+		set("title", title).
+		set("version", version)
+	isoInfo(info, ctxt)
+
+	host, basePath, schemes := serverParts(servers)
+	if host != "" {
+		doc.set("host", host)
+	}
+	if basePath != "" {
+		doc.set("basePath", basePath)
+	}
+	if len(schemes) > 0 {
+		doc.set("schemes", flowStrings(schemes))
+	}
+	consumes := []string{"application/json"}
+	if ctxt.xml {
+		// the body parameter has only one schema, so XML bodies omit the root element
+		consumes = append(consumes, "application/xml")
+	}
+	doc.set("consumes", flowStrings(consumes))
+	doc.set("produces", flowStrings([]string{"application/json"}))
+	globalSecurity(doc, ctxt)
+	return doc
+}
+
+// the default operation for Swagger 2.0: put the message
+func swaggerOperation(put *docMap, msg *message, ctxt *context) {
+	body := newDocMap().
+		set("in", "body").
+		set("name", "body").
+		set("required", true).
+		set("schema", schemaRef(messageRoot(msg, ctxt), ctxt))
+	put.set("parameters", append(sharedParameters(ctxt), body))
+
+	responses := put.child("responses")
+	responses.child("200").set("description", "Happy path")
+	badRequest := responses.child("400")
+	badRequest.set("description", "Bad request (body describes why)")
+	badRequest.set("schema", errorSchema(ctxt))
+	responses.child("429").set("description", "Too Many Requests")
+	responses.child("504").set("description", "Gateway timeout (server did not respond)")
+	// Swagger 2.0 has no 4XX or 5XX ranges
+	responses.child("default").set("description", "Client or Server Error")
+	sharedResponseHeaders(responses, ctxt)
+}
@@ -0,0 +1,305 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeAvro
+// Take the populated data structures and output an Avro schema of the
+// message, or a union of the messages if there are several
+// complex types are records, enumerations are enums and simple types
+// with attributes are records holding the value and the attributes;
+// other simple types are primitives, with logical types for decimals
+// and dates
+// a named type is defined where it's first used, and referred to by name
+// after that, so each shared ISO type appears once
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// entry point for writing
+func writeAvro(f io.Writer, ctxt *context) {
+	for _, name := range includedTypes(ctxt) {
+		if simple, ok := ctxt.simpleTypes[name]; ok && isDecimal(simple) && simple.totalDigits > 0 && simple.fractionDigits < 0 {
+			fmt.Printf("Warning: %s has totalDigits but no fractionDigits, so its Avro scale is 0\n", name)
+		}
+	}
+	defined := make(map[string]bool)
+	inUnion := make(map[string]bool)
+	roots := make(docList, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		root := messageRoot(msg, ctxt)
+		name := avroRootName(msg, ctxt)
+		if inUnion[name] {
+			// another message with the same root
+			continue
+		}
+		inUnion[name] = true
+		if defined[name] {
+			// defined inside an earlier message, so it's referred to by
+			// its full name, as the union is outside the namespace
+			if ns := avroNamespace(ctxt); ns != "" {
+				name = ns + "." + name
+			}
+			roots = append(roots, name)
+			continue
+		}
+		record := avroRootRecord(msg, avroType(root, defined, ctxt), ctxt)
+		record.setBefore("fields", "namespace", avroNamespace(ctxt))
+		roots = append(roots, record)
+	}
+	var schema interface{} = roots
+	if len(roots) == 1 {
+		schema = roots[0]
+	}
+	if err := encodeDoc(f, schema, formatJson); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// the name of the record of a message: the root type, or the record
+// wrapping it if it isn't one
+func avroRootName(msg *message, ctxt *context) string {
+	root := messageRoot(msg, ctxt)
+	if _, ok := ctxt.complexTypes[root]; ok {
+		return root
+	}
+	if simple, ok := ctxt.simpleTypes[root]; ok && len(simple.attrs) > 0 && !ctxt.attrMap.flatten() {
+		return root
+	}
+	return avroName(messageRootTag(msg, ctxt)) + "Document"
+}
+
+// the record of a message
+// a root of a simple type isn't a record, so it's wrapped in one, named
+// for the root tag, e.g. PctDocument, with the root as its field
+func avroRootRecord(msg *message, t interface{}, ctxt *context) *docMap {
+	if record, ok := t.(*docMap); ok {
+		if v, _ := record.get("type"); v == "record" {
+			return record
+		}
+	}
+	tag := messageRootTag(msg, ctxt)
+	return avroRecord(avroName(tag)+"Document", []*docMap{avroField(tag, t, false, false)})
+}
+
+// the namespace: -avronamespace, or from the ISO 20022 message identifier
+func avroNamespace(ctxt *context) string {
+	if ctxt.avroNamespace != "" {
+		return ctxt.avroNamespace
+	}
+	return isoPackage(ctxt)
+}
+
+// a name that Avro allows: letters, digits and _, not starting with a digit
+// e.g. the property @Ccy is the field Ccy
+func avroName(name string) string {
+	name = strings.Trim(regNonName.ReplaceAllString(name, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// the Avro type for an XSD type
+// a record or enum is defined the first time, then it's just its name
+func avroType(name string, defined map[string]bool, ctxt *context) interface{} {
+	if simple, ok := ctxt.simpleTypes[name]; ok {
+		switch {
+		case len(simple.attrs) > 0 && !ctxt.attrMap.flatten():
+			if defined[name] {
+				return name
+			}
+			defined[name] = true
+			return avroRecord(name, wrapperAvroFields(simple, defined, ctxt))
+		case len(simple.enum) > 0:
+			return avroEnum(simple, defined, ctxt)
+		}
+		return avroScalar(simple, ctxt)
+	}
+	if cmplx, ok := ctxt.complexTypes[name]; ok {
+		if defined[name] {
+			return name
+		}
+		defined[name] = true
+		return avroRecord(name, complexAvroFields(cmplx, defined, ctxt))
+	}
+	return avroBuiltin(name, ctxt)
+}
+
+// a record
+// fields whose names clash once made valid get a number
+func avroRecord(name string, fields []*docMap) *docMap {
+	used := make(map[string]bool)
+	list := make(docList, 0, len(fields))
+	for _, field := range fields {
+		v, _ := field.get("name")
+		base := v.(string)
+		fname := base
+		for n := 2; used[fname]; n++ {
+			fname = base + "_" + strconv.Itoa(n)
+		}
+		used[fname] = true
+		field.set("name", fname)
+		list = append(list, field)
+	}
+	return newDocMap().
+		set("type", "record").
+		set("name", name).
+		set("fields", list)
+}
+
+// an enum; the symbols are the codes, made valid if need be
+// symbols that clash once made valid get a number, e.g. A_B and A_B_2
+// the enum of a record with attributes is its Value
+func avroEnum(simple *simpleType, defined map[string]bool, ctxt *context) interface{} {
+	name := simple.name
+	if len(simple.attrs) > 0 && !ctxt.attrMap.flatten() {
+		name += "Value"
+	}
+	if defined[name] {
+		return name
+	}
+	defined[name] = true
+	used := make(map[string]bool)
+	symbols := make([]string, 0, len(simple.enum))
+	for _, v := range simple.enum {
+		symbol := avroName(v)
+		base := symbol
+		for n := 2; used[symbol]; n++ {
+			symbol = base + "_" + strconv.Itoa(n)
+		}
+		used[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	return newDocMap().
+		set("type", "enum").
+		set("name", name).
+		set("symbols", flowStrings(symbols))
+}
+
+// the primitive (and logical) type of a simple type
+// a decimal with totalDigits is a decimal logical type; without, it
+// follows -decimal, as Avro needs the precision
+// without fractionDigits the scale is 0, as the scale can't vary
+func avroScalar(simple *simpleType, ctxt *context) interface{} {
+	total, fraction := decimalDigits(simple)
+	if !isDecimal(simple) || total < 1 {
+		return avroBuiltin(simple.base, ctxt)
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+	return newDocMap().
+		set("type", "bytes").
+		set("logicalType", "decimal").
+		set("precision", total).
+		set("scale", fraction)
+}
+
+// the primitive (and logical) type of an XSD builtin type
+func avroBuiltin(name string, ctxt *context) interface{} {
+	logical := func(t string, lt string) *docMap {
+		return newDocMap().set("type", t).set("logicalType", lt)
+	}
+	switch builtinKind(name) {
+	case kindBoolean:
+		return "boolean"
+	case kindInteger:
+		return "long"
+	case kindFloat:
+		return "double"
+	case kindDecimal:
+		if ctxt.decimalMode == decimalString {
+			return "string"
+		}
+		return "double"
+	case kindDate:
+		return logical("int", "date")
+	case kindDateTime:
+		return logical("long", "timestamp-millis")
+	case kindTime:
+		return logical("int", "time-millis")
+	case kindBinary:
+		return "bytes"
+	}
+	return "string"
+}
+
+// a field: an array if it repeats, a union with null if it's optional
+func avroField(name string, t interface{}, optional bool, repeated bool) *docMap {
+	field := newDocMap().set("name", avroName(name))
+	switch {
+	case repeated:
+		field.set("type", newDocMap().set("type", "array").set("items", t))
+		if optional {
+			field.set("default", docList{})
+		}
+	case optional:
+		field.set("type", docList{"null", t})
+		field.set("default", nil)
+	default:
+		field.set("type", t)
+	}
+	return field
+}
+
+// the fields of a record for a simple type with attributes
+func wrapperAvroFields(simple *simpleType, defined map[string]bool, ctxt *context) []*docMap {
+	var value interface{}
+	if len(simple.enum) > 0 {
+		value = avroEnum(simple, defined, ctxt)
+	} else {
+		value = avroScalar(simple, ctxt)
+	}
+	fields := []*docMap{avroField(ctxt.attrMap.valueName(), value, false, false)}
+	for _, attr := range simple.attrs {
+		t := avroType(attr.atype, defined, ctxt)
+		fields = append(fields, avroField(attrPropName(simple.name, attr.name, ctxt), t, !attr.required, false))
+	}
+	return fields
+}
+
+// the fields of a record for a complex type
+// only one element of a choice is present, so each is optional
+func complexAvroFields(cmplx *complexType, defined map[string]bool, ctxt *context) []*docMap {
+	fields := make([]*docMap, 0)
+	for _, attr := range cmplx.attrs {
+		t := avroType(attr.atype, defined, ctxt)
+		fields = append(fields, avroField(attrPropName(cmplx.name, attr.name, ctxt), t, !attr.required, false))
+	}
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		optional := el.minOccurs == 0 || cmplx.etype == "choice"
+		t := avroType(el.etype, defined, ctxt)
+		fields = append(fields, avroField(propName(cmplx.name, el.name, ctxt), t, optional, el.maxOccurs > 1))
+		if simple, ok := flatAttrType(el, ctxt); ok {
+			for _, attr := range simple.attrs {
+				at := avroType(attr.atype, defined, ctxt)
+				name := flatPropName(cmplx.name, el.name, attr.name, ctxt)
+				fields = append(fields, avroField(name, at, optional || !attr.required, false))
+			}
+		}
+	}
+	return fields
+}
@@ -20,20 +20,26 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 
 	"github.com/lucasjones/reggen"
 )
 
 const tab = "  "
 
+// sample data comes from a fixed seed, so the output is
+// the same each time for the same input
+var sampleRand = rand.New(rand.NewSource(1))
+
 // entry point for writing
-func writeExample(f io.Writer, ctxt *context) {
+func writeExample(f io.Writer, msg *message, ctxt *context) {
 
 	indent := ""
 	path := ""
-	doc := ctxt.complexTypes["Document"]
+	doc := ctxt.complexTypes[msg.root.etype]
 	// fmt.Printf("Got Document%v\n", doc)
 	fmt.Fprintf(f, "%v{\n", indent)
 	writeOne(f, ctxt, doc, path, indent)
@@ -49,31 +55,37 @@ func writeOne(f io.Writer, ctxt *context, cplx *complexType, path string, indent
 		if el.maxOccurs > 1 {
 			arOpen, arClose = "[", "]"
 		}
+		name := propName(cplx.name, el.name, ctxt)
 		if t, ok := ctxt.complexTypes[el.etype]; ok {
 			//process complex type
 			// fmt.Printf("Path:%v(%v)\n", path+"/"+el.name, el.etype)
 			// fmt.Printf("Path:%v\n", path+"/"+el.name)
-			fmt.Fprintf(f, "%v\"%v\": %v{\n", indent+tab, el.name, arOpen)
+			fmt.Fprintf(f, "%v\"%v\": %v{\n", indent+tab, name, arOpen)
 			writeOne(f, ctxt, t, path+"/"+el.name, indent+tab)
 			fmt.Fprintf(f, "%v", arClose)
 		} else {
 			//process simple type
 			s := ctxt.simpleTypes[el.etype]
-			if len(s.attrs) == 0 {
+			if len(s.attrs) == 0 || ctxt.attrMap.flatten() {
 				// fmt.Printf("Path:%v(%v)\n", path+"/"+el.name, s.base)
-				fmt.Fprintf(f, "%v\"%v\": %v %v %v", indent+tab, el.name, arOpen, sampleData(s), arClose)
+				fmt.Fprintf(f, "%v\"%v\": %v %v %v", indent+tab, name, arOpen, sampleData(s, ctxt), arClose)
+				// flattened attributes sit next to the element
+				for _, attr := range s.attrs {
+					atype := ctxt.simpleTypes[attr.atype]
+					fmt.Fprintf(f, ",\n%v\"%v\": %v %v %v", indent+tab, flatPropName(cplx.name, el.name, attr.name, ctxt), arOpen, sampleData(atype, ctxt), arClose)
+				}
 			} else {
 				// fmt.Printf("Path:%v\n", path+"/"+el.name)
-				fmt.Fprintf(f, "%v\"%v\": %v{\n", indent+tab, el.name, arOpen)
+				fmt.Fprintf(f, "%v\"%v\": %v{\n", indent+tab, name, arOpen)
 				// fmt.Printf("Path:%v(%v)\n", path+"/"+el.name+"/value", s.base)
-				fmt.Fprintf(f, "%v\"%v\": %v,\n", indent+tab+tab, "value", sampleData(s))
+				fmt.Fprintf(f, "%v\"%v\": %v,\n", indent+tab+tab, ctxt.attrMap.valueName(), sampleData(s, ctxt))
 				for idx, attr := range s.attrs {
 					if idx > 0 {
 						fmt.Fprintf(f, ",\n")
 					}
 					// fmt.Printf("Path:%v(%v)\n", path+"/"+el.name+"/@"+attr.name, "string")
 					atype := ctxt.simpleTypes[attr.atype]
-					fmt.Fprintf(f, "%v\"%v\": %v", indent+tab+tab, "@"+attr.name, sampleData(atype))
+					fmt.Fprintf(f, "%v\"%v\": %v", indent+tab+tab, attrPropName(s.name, attr.name, ctxt), sampleData(atype, ctxt))
 				}
 				fmt.Fprintf(f, "\n%v}%v", indent+tab, arClose)
 			}
@@ -82,23 +94,31 @@ func writeOne(f io.Writer, ctxt *context, cplx *complexType, path string, indent
 	fmt.Fprintf(f, "\n%v}", indent)
 }
 
-func sampleData(s *simpleType) string {
+func sampleData(s *simpleType, ctxt *context) string {
+	if isDecimal(s) {
+		return sampleDecimal(s, ctxt)
+	}
 	jname, _ := mapTypename(s.base)
 	switch jname {
 	case "boolean":
 		return "true"
 	case "number":
-		return "123456"
+		return fmt.Sprint(sampleNumber(s))
 	case "string":
 		switch {
+		case dateSamples[localName(s.base)] != "":
+			return jsonString(dateSamples[localName(s.base)])
 		case s.pattern != "":
-			str, err := reggen.Generate(s.pattern, 10)
+			patt, _ := translatePattern(s.pattern, goRegex)
+			str, err := generate(patt, 10)
 			if err != nil {
-				panic(err)
+				// the pattern's diagnostics are reported with the schema
+				fmt.Printf("Warning: no sample for %s, pattern %s: %v\n", s.name, patt, err)
+				str = s.name
 			}
-			return fmt.Sprintf("\"%v\"", str)
+			return jsonString(str)
 		case len(s.enum) > 0:
-			return fmt.Sprintf("\"%v\"", s.enum[0])
+			return jsonString(s.enum[0])
 		default:
 			min := s.minLength
 			max := s.maxLength
@@ -112,12 +132,82 @@ func sampleData(s *simpleType) string {
 				max = 1000
 			} // undocumented golang regex limit!
 			patt := fmt.Sprintf("[0-9A-Fa-f]{%v,%v}", min, max)
-			str, err := reggen.Generate(patt, 10)
+			str, err := generate(patt, 10)
 			if err != nil {
 				panic(err)
 			}
-			return fmt.Sprintf("\"%v\"", str)
+			return jsonString(str)
 		}
 	}
 	return s.base
 }
+
+// a string as JSON, quoted and escaped
+func jsonString(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// sample values for the date and time types
+var dateSamples = map[string]string{
+	"dateTime":      "2019-07-01T12:30:00Z",
+	"dateTimeStamp": "2019-07-01T12:30:00Z",
+	"date":          "2019-07-01",
+	"time":          "12:30:00",
+	"gYearMonth":    "2019-07",
+	"gYear":         "2019",
+	"gMonthDay":     "--07-01",
+	"gDay":          "---01",
+	"gMonth":        "--07",
+	"duration":      "P1D",
+}
+
+// a sample integer within the bounds of the type
+func sampleNumber(s *simpleType) int {
+	n := 123456
+	switch {
+	case s.maxInclusive > -1 && n > s.maxInclusive:
+		n = s.maxInclusive
+	case s.maxExclusive > -1 && n >= s.maxExclusive:
+		n = s.maxExclusive - 1
+	}
+	switch {
+	case s.minInclusive > -1 && n < s.minInclusive:
+		n = s.minInclusive
+	case s.minExclusive > -1 && n <= s.minExclusive:
+		n = s.minExclusive + 1
+	}
+	return n
+}
+
+// generate a string matching a Go regex
+// reggen panics on a class that matches nothing, so that's an error too
+func generate(patt string, limit int) (str string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	g, err := reggen.NewGenerator(patt)
+	if err != nil {
+		return "", err
+	}
+	g.SetSeed(sampleRand.Int63())
+	return g.Generate(limit), nil
+}
+
+// sample data as a value for the document model
+func sampleValue(s *simpleType, ctxt *context) interface{} {
+	str := sampleData(s, ctxt)
+	var v interface{}
+	if err := json.Unmarshal([]byte(str), &v); err != nil {
+		return str
+	}
+	if _, ok := v.(float64); ok {
+		return docNumber(str)
+	}
+	return v
+}
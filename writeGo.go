@@ -0,0 +1,473 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeGo
+// Take the populated data structures and output Go types
+// complex types are structs, with json tags for the JSON of the spec and
+// xml tags for the XML; simple types are named types, enumerations with
+// a constant for each value, and simple types with attributes are structs
+// holding the value and the attributes
+// each type has a Validate method, which checks the facets of the XSD
+// and how many times each element occurs
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the Go code being written, and the imports and helpers it needs
+type goWriter struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+	helpers map[string]bool
+	ctxt    *context
+}
+
+// a field of a struct
+type goField struct {
+	name      string
+	gotype    string // without * or []
+	tag       string
+	validate  bool // the type has a Validate method
+	pointer   bool
+	slice     bool
+	minOccurs int
+	maxOccurs int
+	choice    bool
+}
+
+// entry point for writing
+func writeGo(f io.Writer, ctxt *context) {
+	if ctxt.attrMap.flatten() {
+		fmt.Printf("Warning: Go structs keep attributes with their element, so the JSON tags don't follow -attrs flatten\n")
+	}
+	g := &goWriter{imports: map[string]bool{"fmt": true}, helpers: make(map[string]bool), ctxt: ctxt}
+	for _, name := range includedTypes(ctxt) {
+		if simple, ok := ctxt.simpleTypes[name]; ok {
+			if len(simple.attrs) > 0 {
+				g.wrapperType(simple)
+				g.scalarType(goName(name)+"Value", simple)
+			} else {
+				g.scalarType(goName(name), simple)
+			}
+			continue
+		}
+		g.structType(ctxt.complexTypes[name])
+	}
+	for _, msg := range ctxt.messages {
+		g.documentType(msg)
+	}
+	g.writeHelpers()
+
+	var src bytes.Buffer
+	files := make([]string, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		files = append(files, msg.inFileBase)
+	}
+	fmt.Fprintf(&src, "// Code generated by xsd2oas from %s. DO NOT EDIT.\n\n", strings.Join(files, ", "))
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", goPackage(ctxt))
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	fmt.Fprintf(&src, ")\n")
+	src.Write(g.buf.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		fmt.Printf("Go code not formatted: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := f.Write(out); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// the package: -gopackage, or iso20022 for ISO 20022 messages
+func goPackage(ctxt *context) string {
+	switch {
+	case ctxt.goPackage != "":
+		return ctxt.goPackage
+	case len(isoMessages(ctxt)) > 0:
+		return "iso20022"
+	}
+	return "messages"
+}
+
+// an exported Go name: letters and digits, starting with a capital
+func goName(name string) string {
+	name = regNonName.ReplaceAllString(name, "")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "X" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// the field name for a tag, after any expansion
+func goFieldName(tag string, ctxt *context) string {
+	if ctxt.expand {
+		tag = ctxt.abbrevs.expand(tag)
+	}
+	return goName(tag)
+}
+
+// the Go type for an XSD builtin type
+// dates and times are strings, in their XML form
+func goBuiltin(name string, ctxt *context) string {
+	switch builtinKind(name) {
+	case kindBoolean:
+		return "bool"
+	case kindInteger:
+		return "int64"
+	case kindFloat:
+		return "float64"
+	case kindDecimal:
+		if ctxt.decimalMode == decimalString {
+			return "string"
+		}
+		return "float64"
+	}
+	return "string"
+}
+
+// the Go type for an XSD type, and whether it has a Validate method
+func goType(name string, ctxt *context) (string, bool) {
+	if _, ok := ctxt.simpleTypes[name]; ok {
+		return goName(name), true
+	}
+	if _, ok := ctxt.complexTypes[name]; ok {
+		return goName(name), true
+	}
+	return goBuiltin(name, ctxt), false
+}
+
+// the struct tag of a field
+func goTag(json string, xml string, optional bool) string {
+	if optional {
+		json += ",omitempty"
+		xml += ",omitempty"
+	}
+	return fmt.Sprintf("`json:%q xml:%q`", json, xml)
+}
+
+// the field for an attribute
+func goAttrField(attr attribute, json string, ctxt *context) goField {
+	gotype, validate := goType(attr.atype, ctxt)
+	return goField{
+		name:     goFieldName(attr.name, ctxt),
+		gotype:   gotype,
+		tag:      goTag(json, attr.name+",attr", !attr.required),
+		validate: validate,
+		pointer:  !attr.required,
+	}
+}
+
+// write a struct, and its Validate method
+// fields whose names clash get a number
+func (g *goWriter) writeStruct(name string, comment string, fields []goField) {
+	used := make(map[string]bool)
+	for i := range fields {
+		base := fields[i].name
+		for n := 2; used[fields[i].name]; n++ {
+			fields[i].name = base + strconv.Itoa(n)
+		}
+		used[fields[i].name] = true
+	}
+
+	fmt.Fprintf(&g.buf, "\n// %s %s\ntype %s struct {\n", name, comment, name)
+	for _, field := range fields {
+		prefix := ""
+		switch {
+		case field.slice:
+			prefix = "[]"
+		case field.pointer:
+			prefix = "*"
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s%s %s\n", field.name, prefix, field.gotype, field.tag)
+	}
+	fmt.Fprintf(&g.buf, "}\n")
+
+	fmt.Fprintf(&g.buf, "\n// Validate checks the facets of the fields, and how many times each occurs\n")
+	fmt.Fprintf(&g.buf, "func (v *%s) Validate() error {\n", name)
+	choices := make([]string, 0)
+	for _, field := range fields {
+		g.validateField(field)
+		if field.choice {
+			choices = append(choices, field.name)
+		}
+	}
+	if len(choices) > 0 {
+		fmt.Fprintf(&g.buf, "\tn := 0\n")
+		for _, field := range fields {
+			if !field.choice {
+				continue
+			}
+			if field.slice {
+				fmt.Fprintf(&g.buf, "\tif len(v.%s) > 0 {\n\t\tn++\n\t}\n", field.name)
+			} else {
+				fmt.Fprintf(&g.buf, "\tif v.%s != nil {\n\t\tn++\n\t}\n", field.name)
+			}
+		}
+		fmt.Fprintf(&g.buf, "\tif n != 1 {\n\t\treturn fmt.Errorf(\"one of %s is needed, not %%d\", n)\n\t}\n", strings.Join(choices, ", "))
+	}
+	fmt.Fprintf(&g.buf, "\treturn nil\n}\n")
+}
+
+// check a field: its occurrences, then its value
+func (g *goWriter) validateField(field goField) {
+	if field.slice {
+		if field.minOccurs > 0 && !field.choice {
+			fmt.Fprintf(&g.buf, "\tif len(v.%s) < %d {\n\t\treturn fmt.Errorf(\"%s: at least %d needed, not %%d\", len(v.%s))\n\t}\n",
+				field.name, field.minOccurs, field.name, field.minOccurs, field.name)
+		}
+		if field.maxOccurs < 9999999 {
+			fmt.Fprintf(&g.buf, "\tif len(v.%s) > %d {\n\t\treturn fmt.Errorf(\"%s: at most %d allowed, not %%d\", len(v.%s))\n\t}\n",
+				field.name, field.maxOccurs, field.name, field.maxOccurs, field.name)
+		}
+		if field.validate {
+			fmt.Fprintf(&g.buf, "\tfor i := range v.%s {\n\t\tif err := v.%s[i].Validate(); err != nil {\n", field.name, field.name)
+			fmt.Fprintf(&g.buf, "\t\t\treturn fmt.Errorf(\"%s[%%d]: %%v\", i, err)\n\t\t}\n\t}\n", field.name)
+		}
+		return
+	}
+	if !field.validate {
+		return
+	}
+	check := fmt.Sprintf("if err := v.%s.Validate(); err != nil {\n\t\treturn fmt.Errorf(\"%s: %%v\", err)\n\t}", field.name, field.name)
+	if field.pointer {
+		fmt.Fprintf(&g.buf, "\tif v.%s != nil {\n\t\t%s\n\t}\n", field.name, strings.Replace(check, "\n", "\n\t", -1))
+		return
+	}
+	fmt.Fprintf(&g.buf, "\t%s\n", check)
+}
+
+// a struct for a complex type
+// only one element of a choice is present, so each is optional
+func (g *goWriter) structType(cmplx *complexType) {
+	ctxt := g.ctxt
+	fields := make([]goField, 0)
+	for _, attr := range cmplx.attrs {
+		fields = append(fields, goAttrField(attr, attrPropName(cmplx.name, attr.name, ctxt), ctxt))
+	}
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		gotype, validate := goType(el.etype, ctxt)
+		choice := cmplx.etype == "choice"
+		repeated := el.maxOccurs > 1
+		optional := el.minOccurs == 0 || choice
+		fields = append(fields, goField{
+			name:      goFieldName(el.name, ctxt),
+			gotype:    gotype,
+			tag:       goTag(propName(cmplx.name, el.name, ctxt), el.name, optional),
+			validate:  validate,
+			pointer:   optional && !repeated,
+			slice:     repeated,
+			minOccurs: el.minOccurs,
+			maxOccurs: el.maxOccurs,
+			choice:    choice,
+		})
+	}
+	comment := "is the XSD complex type " + cmplx.name
+	if cmplx.etype == "choice" {
+		comment += ", a choice: one field is set"
+	}
+	g.writeStruct(goName(cmplx.name), comment, fields)
+}
+
+// a struct for a simple type with attributes: the value, and the attributes
+func (g *goWriter) wrapperType(simple *simpleType) {
+	ctxt := g.ctxt
+	fields := []goField{{
+		name:     "Value",
+		gotype:   goName(simple.name) + "Value",
+		tag:      fmt.Sprintf("`json:%q xml:\",chardata\"`", ctxt.attrMap.valueName()),
+		validate: true,
+	}}
+	for _, attr := range simple.attrs {
+		fields = append(fields, goAttrField(attr, attrPropName(simple.name, attr.name, ctxt), ctxt))
+	}
+	g.writeStruct(goName(simple.name), "is the XSD type "+simple.name+", a value with attributes", fields)
+}
+
+// the document of a message, the root of the XML, in its namespace
+func (g *goWriter) documentType(msg *message) {
+	root := messageRoot(msg, g.ctxt)
+	tag := messageRootTag(msg, g.ctxt)
+	fields := []goField{{
+		name:     goFieldName(tag, g.ctxt),
+		gotype:   goName(root),
+		tag:      goTag(tag, tag, false),
+		validate: true,
+	}}
+	g.imports["encoding/xml"] = true
+	name := goName(msg.root.etype)
+	fmt.Fprintf(&g.buf, "\n// %s is the XML document of %s\ntype %s struct {\n", name, msg.inFileBase, name)
+	fmt.Fprintf(&g.buf, "\tXMLName xml.Name `json:\"-\" xml:\"%s %s\"`\n", msg.namespace, msg.root.name)
+	fmt.Fprintf(&g.buf, "\t%s %s %s\n}\n", fields[0].name, fields[0].gotype, fields[0].tag)
+	fmt.Fprintf(&g.buf, "\n// Validate checks the message\nfunc (v *%s) Validate() error {\n", name)
+	g.validateField(fields[0])
+	fmt.Fprintf(&g.buf, "\treturn nil\n}\n")
+}
+
+// a named type for a simple type, or the value of one with attributes
+// an enumeration has a constant for each value
+func (g *goWriter) scalarType(name string, simple *simpleType) {
+	gotype := goBuiltin(simple.base, g.ctxt)
+	fmt.Fprintf(&g.buf, "\n// %s is the XSD type %s\ntype %s %s\n", name, simple.name, name, gotype)
+	if len(simple.enum) > 0 {
+		g.enumValues(name, simple)
+		return
+	}
+
+	if gotype == "string" && simple.pattern != "" {
+		patt, diags := translatePattern(simple.pattern, goRegex)
+		for _, d := range diags {
+			fmt.Printf("Pattern for %s: %s\n", simple.name, d)
+		}
+		g.imports["regexp"] = true
+		fmt.Fprintf(&g.buf, "\nvar pattern%s = regexp.MustCompile(%q)\n", name, patt)
+	}
+	fmt.Fprintf(&g.buf, "\n// Validate checks the facets of the XSD type\nfunc (v %s) Validate() error {\n", name)
+	if gotype == "string" {
+		g.validateString(name, simple)
+	}
+	if isDecimal(simple) {
+		g.validateDecimal(simple, gotype)
+	} else if gotype == "int64" || gotype == "float64" {
+		g.validateBounds(simple, "v")
+	}
+	fmt.Fprintf(&g.buf, "\treturn nil\n}\n")
+}
+
+// the constants of an enumeration, and a Validate method that checks for them
+// values that clash once made valid names get a number
+func (g *goWriter) enumValues(name string, simple *simpleType) {
+	consts := make([]string, 0, len(simple.enum))
+	used := make(map[string]bool)
+	fmt.Fprintf(&g.buf, "\n// the values of %s\nconst (\n", name)
+	for _, v := range simple.enum {
+		c := name + regNonName.ReplaceAllString(v, "")
+		base := c
+		for n := 2; used[c]; n++ {
+			c = base + strconv.Itoa(n)
+		}
+		used[c] = true
+		consts = append(consts, c)
+		fmt.Fprintf(&g.buf, "\t%s %s = %q\n", c, name, v)
+	}
+	fmt.Fprintf(&g.buf, ")\n")
+	fmt.Fprintf(&g.buf, "\n// Validate checks the value is one of the enumeration\nfunc (v %s) Validate() error {\n", name)
+	fmt.Fprintf(&g.buf, "\tswitch v {\n\tcase %s:\n\t\treturn nil\n\t}\n", strings.Join(consts, ", "))
+	fmt.Fprintf(&g.buf, "\treturn fmt.Errorf(\"%%q is not a %s value\", string(v))\n}\n", simple.name)
+}
+
+// check the length and pattern of a string
+func (g *goWriter) validateString(name string, simple *simpleType) {
+	min, max := simple.minLength, simple.maxLength
+	if simple.length > -1 {
+		min, max = simple.length, simple.length
+	}
+	if min > 0 || max > -1 {
+		g.imports["unicode/utf8"] = true
+		fmt.Fprintf(&g.buf, "\tn := utf8.RuneCountInString(string(v))\n")
+	}
+	if min > 0 {
+		fmt.Fprintf(&g.buf, "\tif n < %d {\n\t\treturn fmt.Errorf(\"%%q is shorter than %d\", string(v))\n\t}\n", min, min)
+	}
+	if max > -1 {
+		fmt.Fprintf(&g.buf, "\tif n > %d {\n\t\treturn fmt.Errorf(\"%%q is longer than %d\", string(v))\n\t}\n", max, max)
+	}
+	if simple.pattern == "" || isDecimal(simple) {
+		return
+	}
+	fmt.Fprintf(&g.buf, "\tif !pattern%s.MatchString(string(v)) {\n", name)
+	fmt.Fprintf(&g.buf, "\t\treturn fmt.Errorf(\"%%q does not match %%s\", string(v), %s)\n\t}\n", strconv.Quote(simple.pattern))
+}
+
+// check the digits and bounds of a decimal, held as a number or a string
+func (g *goWriter) validateDecimal(simple *simpleType, gotype string) {
+	total, fraction := decimalDigits(simple)
+	g.imports["strconv"] = true
+	if gotype == "string" {
+		fmt.Fprintf(&g.buf, "\tf, err := strconv.ParseFloat(string(v), 64)\n")
+		fmt.Fprintf(&g.buf, "\tif err != nil {\n\t\treturn fmt.Errorf(\"%%q is not a decimal\", string(v))\n\t}\n")
+		fmt.Fprintf(&g.buf, "\ts := string(v)\n")
+	} else {
+		fmt.Fprintf(&g.buf, "\tf := float64(v)\n")
+		fmt.Fprintf(&g.buf, "\ts := strconv.FormatFloat(f, 'f', -1, 64)\n")
+	}
+	if total > -1 || fraction > -1 {
+		g.helpers["checkDigits"] = true
+		g.imports["strings"] = true
+		fmt.Fprintf(&g.buf, "\tif err := checkDigits(s, %d, %d); err != nil {\n\t\treturn err\n\t}\n", total, fraction)
+	} else {
+		fmt.Fprintf(&g.buf, "\t_ = s\n")
+	}
+	g.validateBounds(simple, "f")
+}
+
+// check the bounds of a number
+func (g *goWriter) validateBounds(simple *simpleType, v string) {
+	bound := func(n int, op string, what string) {
+		if n > -1 {
+			fmt.Fprintf(&g.buf, "\tif %s %s %d {\n\t\treturn fmt.Errorf(\"%%v is %s %d\", %s)\n\t}\n", v, op, n, what, n, v)
+		}
+	}
+	bound(simple.minInclusive, "<", "less than")
+	bound(simple.minExclusive, "<=", "not more than")
+	bound(simple.maxInclusive, ">", "more than")
+	bound(simple.maxExclusive, ">=", "not less than")
+}
+
+// the helper functions used by the Validate methods
+func (g *goWriter) writeHelpers() {
+	if g.helpers["checkDigits"] {
+		fmt.Fprintf(&g.buf, `
+// checkDigits checks the totalDigits and fractionDigits of a decimal
+// (-1 if there is no limit)
+func checkDigits(s string, total int, fraction int) error {
+	parts := strings.SplitN(strings.TrimLeft(s, "+-"), ".", 2)
+	whole := strings.TrimLeft(parts[0], "0")
+	frac := ""
+	if len(parts) == 2 {
+		frac = strings.TrimRight(parts[1], "0")
+	}
+	if fraction > -1 && len(frac) > fraction {
+		return fmt.Errorf("%%s has more than %%d fraction digits", s, fraction)
+	}
+	if total > -1 && len(whole)+len(frac) > total {
+		return fmt.Errorf("%%s has more than %%d digits", s, total)
+	}
+	return nil
+}
+`)
+	}
+}
@@ -0,0 +1,364 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeGraphql
+// Take the populated data structures and output a GraphQL schema of the
+// types (SDL)
+// complex types are object types, and a choice is a union, with an object
+// type for each alternative, e.g. AccountIdentification4ChoiceIBAN;
+// enumerations are enums and simple types with attributes are object types
+// holding the value and the attributes
+// other simple types are custom scalars, described by their facets, unless
+// they are plain strings, booleans or floats
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// a field of an object type
+type gqlField struct {
+	name    string
+	gqltype string
+	doc     []string
+}
+
+// the schema being written
+type gqlWriter struct {
+	w        *bufio.Writer
+	names    map[string]bool // the type names used
+	builtins map[string]bool // the XSD builtins that are scalars
+	ctxt     *context
+}
+
+// entry point for writing
+func writeGraphql(f io.Writer, ctxt *context) {
+	g := &gqlWriter{
+		w:        bufio.NewWriter(f),
+		names:    make(map[string]bool),
+		builtins: make(map[string]bool),
+		ctxt:     ctxt,
+	}
+	types := includedTypes(ctxt)
+	for _, name := range types {
+		g.names[gqlName(name)] = true
+	}
+	files := make([]string, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		files = append(files, msg.inFileBase)
+	}
+	fmt.Fprintf(g.w, "# %s\n", strings.Join(files, ", "))
+
+	comments := maskComments(ctxt)
+	for _, name := range types {
+		if simple, ok := ctxt.simpleTypes[name]; ok {
+			g.simple(simple)
+			continue
+		}
+		g.complex(ctxt.complexTypes[name], comments[name])
+	}
+	builtins := make([]string, 0, len(g.builtins))
+	for name := range g.builtins {
+		builtins = append(builtins, name)
+	}
+	sort.Strings(builtins)
+	for _, name := range builtins {
+		g.description("", []string{"XSD " + name})
+		fmt.Fprintf(g.w, "scalar %s\n", gqlBuiltin(name))
+	}
+	if err := g.w.Flush(); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// a name that GraphQL allows: letters, digits and _, not starting with a
+// digit, e.g. the property @Ccy is the field Ccy
+func gqlName(name string) string {
+	name = strings.Trim(regNonName.ReplaceAllString(name, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// is a simple type a plain String, Boolean or Float, rather than a scalar
+// of its own? it is if it has no facets
+func gqlPlain(simple *simpleType) (string, bool) {
+	if simple.pattern != "" || simple.length > -1 || simple.minLength > -1 || simple.maxLength > -1 ||
+		simple.minInclusive > -1 || simple.minExclusive > -1 || simple.maxInclusive > -1 || simple.maxExclusive > -1 ||
+		simple.totalDigits > -1 || simple.fractionDigits > -1 {
+		return "", false
+	}
+	switch builtinKind(simple.base) {
+	case kindString:
+		return "String", localName(simple.base) == "string"
+	case kindBoolean:
+		return "Boolean", true
+	case kindFloat:
+		return "Float", true
+	}
+	return "", false
+}
+
+// the GraphQL type for an XSD type
+// a builtin other than a plain string, boolean or float is a scalar, named
+// for it, e.g. Date
+func (g *gqlWriter) gqlType(name string) string {
+	if simple, ok := g.ctxt.simpleTypes[name]; ok {
+		if !g.wrapped(simple) && len(simple.enum) == 0 {
+			if plain, ok := gqlPlain(simple); ok {
+				return plain
+			}
+		}
+		return gqlName(name)
+	}
+	if _, ok := g.ctxt.complexTypes[name]; ok {
+		return gqlName(name)
+	}
+	if plain, ok := gqlPlain(newBuiltinType(name)); ok {
+		return plain
+	}
+	g.builtins[localName(name)] = true
+	return gqlBuiltin(name)
+}
+
+// the scalar for a builtin, e.g. Date
+func gqlBuiltin(name string) string {
+	name = gqlName(localName(name))
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// is a simple type an object type, with its attributes?
+// with -attrs flatten, the attributes are with the parent, so it's the value
+func (g *gqlWriter) wrapped(simple *simpleType) bool {
+	return len(simple.attrs) > 0 && !g.ctxt.attrMap.flatten()
+}
+
+// a simple type for a builtin, without facets
+func newBuiltinType(name string) *simpleType {
+	simple := newSimpleType(name)
+	simple.base = name
+	return simple
+}
+
+// the type of a field: a list if it repeats, and non-null if it's mandatory
+func gqlFieldType(t string, mandatory bool, repeated bool) string {
+	if repeated {
+		t = "[" + t + "!]"
+	}
+	if mandatory {
+		t += "!"
+	}
+	return t
+}
+
+// write a description, if there's anything to say
+func (g *gqlWriter) description(indent string, lines []string) {
+	text := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l != "" {
+			text = append(text, strings.Replace(l, `"""`, `\"""`, -1))
+		}
+	}
+	if len(text) > 0 {
+		fmt.Fprintf(g.w, "%s\"\"\"%s\"\"\"\n", indent, strings.Join(text, "; "))
+	}
+}
+
+// write an object type
+// GraphQL needs a field, so a type with none included gets a placeholder
+func (g *gqlWriter) object(name string, doc []string, fields []gqlField) {
+	if len(fields) == 0 {
+		fields = []gqlField{{name: "_empty", gqltype: "Boolean", doc: []string{"none of the fields is included"}}}
+	}
+	used := make(map[string]bool)
+	fmt.Fprintf(g.w, "\n")
+	g.description("", doc)
+	fmt.Fprintf(g.w, "type %s {\n", name)
+	for _, field := range fields {
+		fname := field.name
+		for n := 2; used[fname]; n++ {
+			fname = fmt.Sprintf("%s_%d", field.name, n)
+		}
+		used[fname] = true
+		g.description("  ", field.doc)
+		fmt.Fprintf(g.w, "  %s: %s\n", fname, field.gqltype)
+	}
+	fmt.Fprintf(g.w, "}\n")
+}
+
+// a simple type: an enum, an object type if it has attributes, or a scalar
+// (nothing if it's a plain String etc.)
+func (g *gqlWriter) simple(simple *simpleType) {
+	ctxt := g.ctxt
+	name := gqlName(simple.name)
+	value := name
+	if g.wrapped(simple) {
+		value = name + "Value"
+		fields := []gqlField{{name: gqlName(ctxt.attrMap.valueName()), gqltype: value + "!"}}
+		if plain, ok := gqlPlain(simple); ok && len(simple.enum) == 0 {
+			fields[0].gqltype = plain + "!"
+		}
+		for _, attr := range simple.attrs {
+			fields = append(fields, gqlField{
+				name:    gqlName(attrPropName(simple.name, attr.name, ctxt)),
+				gqltype: gqlFieldType(g.gqlType(attr.atype), attr.required, false),
+			})
+		}
+		g.object(name, []string{simple.doc}, fields)
+	}
+
+	switch {
+	case len(simple.enum) > 0:
+		fmt.Fprintf(g.w, "\n")
+		if !g.wrapped(simple) {
+			g.description("", []string{simple.doc})
+		}
+		fmt.Fprintf(g.w, "enum %s {\n", value)
+		// values that clash once made valid get a number, e.g. A_B and
+		// A_B_2, and one that isn't the XSD value is described by it
+		used := make(map[string]bool)
+		for _, v := range simple.enum {
+			name := gqlEnumValue(v)
+			base := name
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s_%d", base, n)
+			}
+			used[name] = true
+			if name != v {
+				g.description("  ", []string{"XSD " + v})
+			}
+			fmt.Fprintf(g.w, "  %s\n", name)
+		}
+		fmt.Fprintf(g.w, "}\n")
+	case g.wrapped(simple):
+		if _, ok := gqlPlain(simple); ok {
+			return
+		}
+		fmt.Fprintf(g.w, "\n")
+		g.description("", []string{gqlFacets(simple)})
+		fmt.Fprintf(g.w, "scalar %s\n", value)
+	default:
+		if _, ok := gqlPlain(simple); ok {
+			return
+		}
+		fmt.Fprintf(g.w, "\n")
+		g.description("", []string{simple.doc, gqlFacets(simple)})
+		fmt.Fprintf(g.w, "scalar %s\n", value)
+	}
+}
+
+// an enum value: a name, but not true, false or null
+func gqlEnumValue(v string) string {
+	name := gqlName(v)
+	switch name {
+	case "true", "false", "null":
+		name = "_" + name
+	}
+	return name
+}
+
+// describe a scalar by its XSD type and facets, e.g.
+// XSD decimal, totalDigits 18, fractionDigits 5, minInclusive 0
+func gqlFacets(simple *simpleType) string {
+	facets := []string{"XSD " + localName(simple.base)}
+	add := func(name string, n int) {
+		if n > -1 {
+			facets = append(facets, fmt.Sprintf("%s %d", name, n))
+		}
+	}
+	add("length", simple.length)
+	add("minLength", simple.minLength)
+	add("maxLength", simple.maxLength)
+	add("totalDigits", simple.totalDigits)
+	add("fractionDigits", simple.fractionDigits)
+	add("minInclusive", simple.minInclusive)
+	add("minExclusive", simple.minExclusive)
+	add("maxInclusive", simple.maxInclusive)
+	add("maxExclusive", simple.maxExclusive)
+	if simple.pattern != "" {
+		facets = append(facets, "pattern "+simple.pattern)
+	}
+	return strings.Join(facets, ", ")
+}
+
+// a complex type: an object type, or a union for a choice
+func (g *gqlWriter) complex(cmplx *complexType, comments map[string][]string) {
+	ctxt := g.ctxt
+	attrs := make([]gqlField, 0, len(cmplx.attrs))
+	for _, attr := range cmplx.attrs {
+		attrs = append(attrs, gqlField{
+			name:    gqlName(attrPropName(cmplx.name, attr.name, ctxt)),
+			gqltype: gqlFieldType(g.gqlType(attr.atype), attr.required, false),
+		})
+	}
+	choice := cmplx.etype == "choice"
+	// the fields of each element: it, and any flattened attributes
+	elems := make([][]gqlField, 0, len(cmplx.elems))
+	tags := make([]string, 0, len(cmplx.elems))
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		mandatory := el.minOccurs != 0 || choice
+		fields := []gqlField{{
+			name:    gqlName(propName(cmplx.name, el.name, ctxt)),
+			gqltype: gqlFieldType(g.gqlType(el.etype), mandatory, el.maxOccurs > 1),
+			doc:     append([]string{el.doc}, comments[el.name]...),
+		}}
+		if simple, ok := flatAttrType(el, ctxt); ok {
+			for _, attr := range simple.attrs {
+				fields = append(fields, gqlField{
+					name:    gqlName(flatPropName(cmplx.name, el.name, attr.name, ctxt)),
+					gqltype: gqlFieldType(g.gqlType(attr.atype), mandatory && attr.required, false),
+				})
+			}
+		}
+		elems = append(elems, fields)
+		tags = append(tags, el.name)
+	}
+
+	name := gqlName(cmplx.name)
+	if !choice || len(elems) == 0 {
+		fields := attrs
+		for _, f := range elems {
+			fields = append(fields, f...)
+		}
+		g.object(name, []string{cmplx.doc}, fields)
+		return
+	}
+	// each alternative is an object type, named for the choice and element
+	members := make([]string, 0, len(elems))
+	for i, fields := range elems {
+		member := name + gqlName(tags[i])
+		for n := 2; g.names[member]; n++ {
+			member = fmt.Sprintf("%s%s%d", name, gqlName(tags[i]), n)
+		}
+		g.names[member] = true
+		members = append(members, member)
+		g.object(member, []string{fmt.Sprintf("%s of %s", tags[i], cmplx.name)}, append(append([]gqlField{}, attrs...), fields...))
+	}
+	fmt.Fprintf(g.w, "\n")
+	g.description("", []string{cmplx.doc})
+	fmt.Fprintf(g.w, "union %s = %s\n", name, strings.Join(members, " | "))
+}
@@ -0,0 +1,74 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeJsonSchema
+// Take the populated data structures and output a standalone JSON schema
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// JSON schema drafts
+const (
+	draft07   = "07"
+	draft2020 = "2020-12"
+)
+
+// entry point for writing
+func writeJsonSchema(f io.Writer, ctxt *context) {
+	// both drafts share the 3.1 rules (numeric exclusive bounds, const etc.)
+	// they differ in where the definitions go
+	jctxt := *ctxt
+	jctxt.oasVersion = oas31
+	jctxt.xml = false
+	defs, uri := "$defs", "https://json-schema.org/draft/2020-12/schema"
+	if ctxt.jsonDraft == draft07 {
+		defs, uri = "definitions", "http://json-schema.org/draft-07/schema#"
+	}
+	jctxt.refPrefix = "#/" + defs + "/"
+
+	roots := make([]string, 0)
+	refs := make(docList, 0)
+	for _, msg := range ctxt.messages {
+		root := messageRoot(msg, ctxt)
+		roots = append(roots, root)
+		refs = append(refs, schemaRef(root, &jctxt))
+	}
+	doc := newDocMap()
+	doc.set("$schema", uri)
+	doc.set("title", strings.Join(roots, ", "))
+	switch {
+	case len(refs) > 1:
+		// with several messages, an instance is any one of them
+		doc.set("oneOf", refs)
+	case ctxt.jsonDraft == draft07:
+		// siblings of $ref are ignored in draft-07
+		doc.set("allOf", refs)
+	default:
+		doc.set("$ref", jctxt.refPrefix+roots[0])
+	}
+	doc.set(defs, buildSchemas(&jctxt))
+
+	if err := encodeDoc(f, doc, formatJson); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,424 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeProto
+// Take the populated data structures and output proto3 definitions
+// complex types are messages, enumerations are enums and simple types
+// with attributes are wrapper messages, holding the value and the
+// attributes; other simple types are scalars
+// each field keeps its JSON property name as json_name, so the proto
+// JSON mapping matches the spec (except for enum values, which proto
+// prefixes with the enum name)
+//
+// field numbers must not change between versions, so they can be kept
+// in a numbers file, which is read and updated each time
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// field and enum value numbers, by Owner.name
+type protoNumbers map[string]int
+
+// read the numbers file, if there is one yet
+// each line is Owner.name number, e.g. GroupHeader93.msg_id 1
+func readProtoNumbers(fname string) protoNumbers {
+	nums := make(protoNumbers)
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return nums
+	}
+	if err != nil {
+		fmt.Printf("File %v open err %v", fname, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(strings.Split(scanner.Text(), "#")[0])
+		if len(fields) == 0 {
+			continue
+		}
+		n := 0
+		if len(fields) == 2 {
+			n, err = strconv.Atoi(fields[1])
+		}
+		if len(fields) != 2 || err != nil || !strings.Contains(fields[0], ".") {
+			fmt.Printf("File %v line %d: must be Owner.name number\n", fname, line)
+			os.Exit(2)
+		}
+		nums[fields[0]] = n
+	}
+	return nums
+}
+
+// write the numbers file, by owner then number
+func writeProtoNumbers(fname string, nums protoNumbers) {
+	keys := make([]string, 0, len(nums))
+	for k := range nums {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		oi, oj := protoOwner(keys[i]), protoOwner(keys[j])
+		if oi != oj {
+			return oi < oj
+		}
+		return nums[keys[i]] < nums[keys[j]]
+	})
+	f, err := os.Create(fname)
+	if err != nil {
+		fmt.Printf("File %v open err %v", fname, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "# proto field numbers: keep with the .proto, and don't edit the numbers\n")
+	for _, k := range keys {
+		fmt.Fprintf(f, "%s %d\n", k, nums[k])
+	}
+}
+
+// the owner part of a numbers key
+func protoOwner(key string) string {
+	return key[:strings.Index(key, ".")]
+}
+
+// number the names of an owner, keeping the numbers already given
+// new names get numbers after any used before, so they are never reused;
+// the numbers and names that have gone are returned, to be reserved
+func (p protoNumbers) assign(owner string, names []string, first int) (map[string]int, []int, []string) {
+	nums := make(map[string]int)
+	current := make(map[string]bool)
+	for _, name := range names {
+		current[name] = true
+	}
+	max := first - 1
+	reserved := make([]int, 0)
+	reservedNames := make([]string, 0)
+	for key, n := range p {
+		if protoOwner(key) != owner {
+			continue
+		}
+		if n > max {
+			max = n
+		}
+		if name := key[len(owner)+1:]; !current[name] {
+			reserved = append(reserved, n)
+			reservedNames = append(reservedNames, name)
+		}
+	}
+	for _, name := range names {
+		if n, ok := p[owner+"."+name]; ok {
+			nums[name] = n
+			continue
+		}
+		max++
+		nums[name] = max
+		p[owner+"."+name] = max
+	}
+	sort.Ints(reserved)
+	sort.Strings(reservedNames)
+	return nums, reserved, reservedNames
+}
+
+// a field of a message
+type protoField struct {
+	name    string
+	ptype   string
+	label   string // optional | repeated, or empty
+	json    string // the JSON property name
+	comment string // the XSD type of a scalar
+	choice  bool   // in the oneof of a choice
+}
+
+// entry point for writing
+func writeProto(f io.Writer, ctxt *context) {
+	nums := make(protoNumbers)
+	if ctxt.protoNumFile != "" {
+		nums = readProtoNumbers(ctxt.protoNumFile)
+	}
+	w := bufio.NewWriter(f)
+	files := make([]string, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		files = append(files, msg.inFileBase)
+	}
+	fmt.Fprintf(w, "// %s\n", strings.Join(files, ", "))
+	fmt.Fprintf(w, "syntax = \"proto3\";\n\npackage %s;\n", protoPackage(ctxt))
+
+	for _, name := range includedTypes(ctxt) {
+		if simple, ok := ctxt.simpleTypes[name]; ok {
+			if len(simple.enum) > 0 {
+				writeProtoEnum(w, simple, nums, ctxt)
+			}
+			if len(simple.attrs) > 0 && !ctxt.attrMap.flatten() {
+				writeProtoMessage(w, name, wrapperFields(simple, ctxt), nums)
+			}
+			continue
+		}
+		cmplx := ctxt.complexTypes[name]
+		writeProtoMessage(w, name, complexFields(cmplx, ctxt), nums)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+	if ctxt.protoNumFile != "" {
+		writeProtoNumbers(ctxt.protoNumFile, nums)
+	}
+}
+
+// the package: -protopackage, or from the ISO 20022 message identifier
+func protoPackage(ctxt *context) string {
+	if ctxt.protoPackage != "" {
+		return ctxt.protoPackage
+	}
+	return isoPackage(ctxt)
+}
+
+// a name that proto allows: letters, digits and _, lowercase
+func protoName(name string) string {
+	name = strings.Trim(regNonName.ReplaceAllString(name, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "f_" + name
+	}
+	return strings.ToLower(name)
+}
+
+// the field name for a tag: snake_case, after any expansion
+func protoFieldName(tag string, ctxt *context) string {
+	if ctxt.expand {
+		tag = ctxt.abbrevs.expand(tag)
+	}
+	return protoName(namingStrategies["snake"](tag))
+}
+
+// the name of the enum of a simple type
+// a wrapper message has the type's name, so its enum is the Value
+func protoEnumName(simple *simpleType, ctxt *context) string {
+	if len(simple.attrs) > 0 && !ctxt.attrMap.flatten() {
+		return simple.name + "Value"
+	}
+	return simple.name
+}
+
+// the scalar type for an XSD builtin type
+// decimals follow -decimal, as proto has no decimal type
+func protoScalar(base string, ctxt *context) string {
+	switch builtinKind(base) {
+	case kindBoolean:
+		return "bool"
+	case kindInteger:
+		return "int64"
+	case kindFloat:
+		return "double"
+	case kindDecimal:
+		if ctxt.decimalMode == decimalString {
+			return "string"
+		}
+		return "double"
+	case kindBinary:
+		return "bytes"
+	}
+	return "string"
+}
+
+// the proto type for an XSD type, and whether it's a scalar (or enum),
+// which needs optional for presence; a scalar notes the XSD type
+func protoType(name string, ctxt *context) (string, bool, string) {
+	if simple, ok := ctxt.simpleTypes[name]; ok {
+		switch {
+		case len(simple.attrs) > 0 && !ctxt.attrMap.flatten():
+			return simple.name, false, ""
+		case len(simple.enum) > 0:
+			return protoEnumName(simple, ctxt), true, ""
+		}
+		return protoScalar(simple.base, ctxt), true, simple.name
+	}
+	if _, ok := ctxt.complexTypes[name]; ok {
+		return name, false, ""
+	}
+	return protoScalar(name, ctxt), true, ""
+}
+
+// the field for an attribute
+func attrField(attr attribute, name string, json string, ctxt *context) protoField {
+	ptype, _, comment := protoType(attr.atype, ctxt)
+	field := protoField{name: name, ptype: ptype, json: json, comment: comment}
+	if !attr.required {
+		field.label = "optional"
+	}
+	return field
+}
+
+// the fields of a wrapper message: the value, and the attributes
+func wrapperFields(simple *simpleType, ctxt *context) []protoField {
+	value := protoField{name: "value", json: ctxt.attrMap.valueName()}
+	if len(simple.enum) > 0 {
+		value.ptype = protoEnumName(simple, ctxt)
+	} else {
+		value.ptype = protoScalar(simple.base, ctxt)
+	}
+	fields := []protoField{value}
+	for _, attr := range simple.attrs {
+		fields = append(fields, attrField(attr, protoFieldName(attr.name, ctxt), attrPropName(simple.name, attr.name, ctxt), ctxt))
+	}
+	return fields
+}
+
+// the fields of a complex type
+// the elements of a choice are a oneof, except repeated ones, which
+// proto doesn't allow there
+func complexFields(cmplx *complexType, ctxt *context) []protoField {
+	fields := make([]protoField, 0)
+	for _, attr := range cmplx.attrs {
+		fields = append(fields, attrField(attr, protoFieldName(attr.name, ctxt), attrPropName(cmplx.name, attr.name, ctxt), ctxt))
+	}
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		ptype, scalar, comment := protoType(el.etype, ctxt)
+		field := protoField{
+			name:    protoFieldName(el.name, ctxt),
+			ptype:   ptype,
+			json:    propName(cmplx.name, el.name, ctxt),
+			comment: comment,
+		}
+		switch {
+		case el.maxOccurs > 1:
+			field.label = "repeated"
+		case cmplx.etype == "choice":
+			field.choice = true
+		case scalar && el.minOccurs == 0:
+			field.label = "optional"
+		}
+		fields = append(fields, field)
+		if simple, ok := flatAttrType(el, ctxt); ok {
+			for _, attr := range simple.attrs {
+				af := attrField(attr, protoFieldName(el.name+attr.name, ctxt), flatPropName(cmplx.name, el.name, attr.name, ctxt), ctxt)
+				if el.minOccurs == 0 {
+					af.label = "optional"
+				}
+				fields = append(fields, af)
+			}
+		}
+	}
+	return fields
+}
+
+// write a message
+func writeProtoMessage(w io.Writer, name string, fields []protoField, nums protoNumbers) {
+	// names that clash once in snake_case get a number
+	used := make(map[string]bool)
+	names := make([]string, 0, len(fields))
+	for i := range fields {
+		base := fields[i].name
+		for n := 2; used[fields[i].name]; n++ {
+			fields[i].name = base + "_" + strconv.Itoa(n)
+		}
+		used[fields[i].name] = true
+		names = append(names, fields[i].name)
+	}
+	numbers, reserved, reservedNames := nums.assign(name, names, 1)
+
+	fmt.Fprintf(w, "\nmessage %s {\n", name)
+	choices := make([]protoField, 0)
+	for _, field := range fields {
+		if field.choice {
+			choices = append(choices, field)
+			continue
+		}
+		writeProtoField(w, "  ", field, numbers[field.name])
+	}
+	if len(choices) > 0 {
+		fmt.Fprintf(w, "  oneof choice {\n")
+		for _, field := range choices {
+			writeProtoField(w, "    ", field, numbers[field.name])
+		}
+		fmt.Fprintf(w, "  }\n")
+	}
+	writeProtoReserved(w, reserved, reservedNames)
+	fmt.Fprintf(w, "}\n")
+}
+
+// write a field
+func writeProtoField(w io.Writer, indent string, field protoField, number int) {
+	label := ""
+	if field.label != "" {
+		label = field.label + " "
+	}
+	fmt.Fprintf(w, "%s%s%s %s = %d [json_name = %q];", indent, label, field.ptype, field.name, number, field.json)
+	if field.comment != "" {
+		fmt.Fprintf(w, " // %s", field.comment)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// write the reserved numbers and names
+func writeProtoReserved(w io.Writer, reserved []int, names []string) {
+	if len(reserved) == 0 {
+		return
+	}
+	strs := make([]string, 0, len(reserved))
+	for _, n := range reserved {
+		strs = append(strs, strconv.Itoa(n))
+	}
+	fmt.Fprintf(w, "  reserved %s;\n", strings.Join(strs, ", "))
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, strconv.Quote(name))
+	}
+	fmt.Fprintf(w, "  reserved %s;\n", strings.Join(quoted, ", "))
+}
+
+// write an enum
+// the values are prefixed with the enum name, as proto enum values share
+// the scope of the enum; values that clash once converted get a number
+func writeProtoEnum(w io.Writer, simple *simpleType, nums protoNumbers, ctxt *context) {
+	name := protoEnumName(simple, ctxt)
+	prefix := strings.ToUpper(protoName(namingStrategies["snake"](name))) + "_"
+	used := map[string]bool{prefix + "UNSPECIFIED": true}
+	values := make([]string, 0, len(simple.enum))
+	for _, v := range simple.enum {
+		value := prefix + strings.ToUpper(strings.Trim(regNonName.ReplaceAllString(v, "_"), "_"))
+		base := value
+		for n := 2; used[value]; n++ {
+			value = base + "_" + strconv.Itoa(n)
+		}
+		used[value] = true
+		values = append(values, value)
+	}
+	numbers, reserved, reservedNames := nums.assign(name, values, 1)
+
+	fmt.Fprintf(w, "\nenum %s {\n", name)
+	fmt.Fprintf(w, "  %sUNSPECIFIED = 0;\n", prefix)
+	for i, value := range values {
+		fmt.Fprintf(w, "  %s = %d;", value, numbers[value])
+		if simple.enum[i] != value[len(prefix):] {
+			fmt.Fprintf(w, " // %s", simple.enum[i])
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	writeProtoReserved(w, reserved, reservedNames)
+	fmt.Fprintf(w, "}\n")
+}
@@ -0,0 +1,92 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the numbers of a message as it changes between runs
+func TestProtoNumbersAssign(t *testing.T) {
+	nums := make(protoNumbers)
+	tests := []struct {
+		names    []string
+		want     map[string]int
+		reserved []int
+		gone     []string
+	}{
+		{[]string{"msg_id", "cre_dt_tm", "nb_of_txs"},
+			map[string]int{"msg_id": 1, "cre_dt_tm": 2, "nb_of_txs": 3}, []int{}, []string{}},
+		// reordered: the numbers go with the names
+		{[]string{"nb_of_txs", "msg_id", "cre_dt_tm"},
+			map[string]int{"msg_id": 1, "cre_dt_tm": 2, "nb_of_txs": 3}, []int{}, []string{}},
+		// removed: its number is reserved
+		{[]string{"nb_of_txs", "msg_id"},
+			map[string]int{"msg_id": 1, "nb_of_txs": 3}, []int{2}, []string{"cre_dt_tm"}},
+		// added: a new number, not the reserved one
+		{[]string{"nb_of_txs", "msg_id", "sttlm_inf"},
+			map[string]int{"msg_id": 1, "nb_of_txs": 3, "sttlm_inf": 4}, []int{2}, []string{"cre_dt_tm"}},
+	}
+	for i, tt := range tests {
+		got, reserved, gone := nums.assign("GroupHeader93", tt.names, 1)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("run %d: assign(%v) = %v, want %v", i+1, tt.names, got, tt.want)
+		}
+		if !reflect.DeepEqual(reserved, tt.reserved) || !reflect.DeepEqual(gone, tt.gone) {
+			t.Errorf("run %d: assign(%v) reserved %v %v, want %v %v", i+1, tt.names, reserved, gone, tt.reserved, tt.gone)
+		}
+	}
+}
+
+// the numbers are kept in the numbers file from one run to the next
+func TestProtoNumbersFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "numbers.txt")
+	fields := func(names ...string) []protoField {
+		l := make([]protoField, 0, len(names))
+		for _, name := range names {
+			l = append(l, protoField{name: name, ptype: "string", json: name})
+		}
+		return l
+	}
+	runs := []struct {
+		fields []protoField
+		want   []string
+	}{
+		{fields("msg_id", "cre_dt_tm", "nb_of_txs"), []string{
+			`msg_id = 1 [`, `cre_dt_tm = 2 [`, `nb_of_txs = 3 [`}},
+		{fields("nb_of_txs", "msg_id"), []string{
+			`nb_of_txs = 3 [`, `msg_id = 1 [`, `reserved 2;`, `reserved "cre_dt_tm";`}},
+		{fields("msg_id", "ctrl_sum", "nb_of_txs"), []string{
+			`msg_id = 1 [`, `ctrl_sum = 4 [`, `nb_of_txs = 3 [`, `reserved 2;`}},
+	}
+	for i, run := range runs {
+		nums := readProtoNumbers(fname)
+		var buf bytes.Buffer
+		writeProtoMessage(&buf, "GroupHeader93", run.fields, nums)
+		writeProtoNumbers(fname, nums)
+		out := buf.String()
+		for _, want := range run.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("run %d: no %s in\n%s", i+1, want, out)
+			}
+		}
+	}
+}
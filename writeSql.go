@@ -0,0 +1,365 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeSql
+// Take the populated data structures and output SQL tables to store
+// the included fields of the messages
+// the message is a table, and so is each repeating element, e.g.
+// CdtTrfTxInf, with a foreign key to its parent and its position;
+// everything else is flattened into columns of the table it's in, named
+// by the path from there, e.g. grp_hdr_msg_id
+// the columns are typed from the facets of the XSD: VARCHAR(maxLength),
+// NUMERIC(totalDigits, fractionDigits), and a CHECK for enumerations
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SQL dialects
+const (
+	sqlPostgres = "postgres"
+	sqlSqlite   = "sqlite"
+)
+
+// the longest name PostgreSQL allows
+const sqlMaxName = 63
+
+// a table: a message, or a repeating element
+type sqlTable struct {
+	name    string
+	path    string // of the element, e.g. /FIToFICstmrCdtTrf/CdtTrfTxInf
+	xsdType string
+	parent  *sqlTable
+	columns []sqlColumn
+	used    map[string]bool // the column names
+}
+
+// a column
+type sqlColumn struct {
+	name    string
+	ctype   string
+	notNull bool
+	check   string // a CHECK constraint, if any
+	path    string // from the table, e.g. GrpHdr/MsgId
+}
+
+// entry point for writing
+func writeSql(f io.Writer, ctxt *context) {
+	tables := make([]*sqlTable, 0)
+	names := make(map[string]bool)
+	for _, msg := range ctxt.messages {
+		root := messageRoot(msg, ctxt)
+		tag := messageRootTag(msg, ctxt)
+		table := newSqlTable(sqlName(tag, ctxt), "/"+tag, root, nil, names)
+		tables = append(tables, table)
+		if _, ok := ctxt.complexTypes[root]; ok {
+			tables = sqlFields(table, tables, root, "", "", true, names, map[string]bool{}, ctxt)
+		} else {
+			// a root of a simple type is a column of its own
+			tables = sqlContent(table, tables, root, sqlName(tag, ctxt), tag, true, names, map[string]bool{}, ctxt)
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	files := make([]string, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		files = append(files, msg.inFileBase)
+	}
+	fmt.Fprintf(w, "-- %s tables for %s\n", ctxt.sqlDialect, strings.Join(files, ", "))
+	for _, table := range tables {
+		writeSqlTable(w, table, ctxt)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// a table, with a name no other table has
+// a repeating element is named for its tag, or else for its parent and tag
+func newSqlTable(name string, path string, xsdType string, parent *sqlTable, names map[string]bool) *sqlTable {
+	if names[name] && parent != nil {
+		name = parent.name + "_" + name
+	}
+	base := name
+	for n := 2; names[name]; n++ {
+		name = base + "_" + strconv.Itoa(n)
+	}
+	names[name] = true
+	if len(name) > sqlMaxName {
+		fmt.Printf("Warning: table name %s is longer than %d characters\n", name, sqlMaxName)
+	}
+	return &sqlTable{
+		name:    name,
+		path:    path,
+		xsdType: xsdType,
+		parent:  parent,
+		used:    map[string]bool{"id": true, "seq": true},
+	}
+}
+
+// add a column, with a name no other column of the table has
+func (t *sqlTable) add(col sqlColumn) {
+	if col.name == "" {
+		col.name = "value"
+	}
+	base := col.name
+	for n := 2; t.used[col.name]; n++ {
+		col.name = base + "_" + strconv.Itoa(n)
+	}
+	t.used[col.name] = true
+	if len(col.name) > sqlMaxName {
+		fmt.Printf("Warning: column name %s.%s is longer than %d characters\n", t.name, col.name, sqlMaxName)
+	}
+	t.columns = append(t.columns, col)
+}
+
+// the column name for a tag: snake_case, after any expansion
+func sqlName(tag string, ctxt *context) string {
+	if ctxt.expand {
+		tag = ctxt.abbrevs.expand(tag)
+	}
+	return strings.ToLower(strings.Trim(regNonName.ReplaceAllString(namingStrategies["snake"](tag), "_"), "_"))
+}
+
+// join a column prefix and name
+func sqlJoin(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// join a path and tag
+func pathJoin(path string, tag string) string {
+	if path == "" {
+		return tag
+	}
+	return path + "/" + tag
+}
+
+// add the columns of a complex type to a table, and tables for its
+// repeating elements
+// required is whether the type is always there (no element of a choice is)
+// seen marks the types on the way down, so a recursive type stops
+func sqlFields(table *sqlTable, tables []*sqlTable, typeName string, prefix string, path string, required bool,
+	names map[string]bool, seen map[string]bool, ctxt *context) []*sqlTable {
+	cmplx := ctxt.complexTypes[typeName]
+	if seen[typeName] {
+		fmt.Printf("Warning: %s/%s is recursive, and isn't stored\n", table.path, path)
+		return tables
+	}
+	seen[typeName] = true
+	defer delete(seen, typeName)
+
+	for _, attr := range cmplx.attrs {
+		sqlValue(table, attr.atype, sqlJoin(prefix, sqlName(attr.name, ctxt)), pathJoin(path, "@"+attr.name), required && attr.required, ctxt)
+	}
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		elPath := pathJoin(path, el.name)
+		if el.maxOccurs > 1 {
+			child := newSqlTable(sqlName(el.name, ctxt), table.path+"/"+elPath, el.etype, table, names)
+			tables = append(tables, child)
+			tables = sqlContent(child, tables, el.etype, "", "", true, names, seen, ctxt)
+			continue
+		}
+		elRequired := required && el.minOccurs != 0 && cmplx.etype != "choice"
+		tables = sqlContent(table, tables, el.etype, sqlJoin(prefix, sqlName(el.name, ctxt)), elPath, elRequired, names, seen, ctxt)
+	}
+	return tables
+}
+
+// add the columns for the content of an element: those of its complex
+// type, or its value and attributes
+func sqlContent(table *sqlTable, tables []*sqlTable, typeName string, prefix string, path string, required bool,
+	names map[string]bool, seen map[string]bool, ctxt *context) []*sqlTable {
+	if _, ok := ctxt.complexTypes[typeName]; ok {
+		return sqlFields(table, tables, typeName, prefix, path, required, names, seen, ctxt)
+	}
+	sqlValue(table, typeName, prefix, path, required, ctxt)
+	if simple, ok := ctxt.simpleTypes[typeName]; ok {
+		for _, attr := range simple.attrs {
+			sqlValue(table, attr.atype, sqlJoin(prefix, sqlName(attr.name, ctxt)), pathJoin(path, "@"+attr.name), required && attr.required, ctxt)
+		}
+	}
+	return tables
+}
+
+// add the column for a value of a simple or builtin type
+func sqlValue(table *sqlTable, typeName string, name string, path string, required bool, ctxt *context) {
+	col := sqlColumn{name: name, notNull: required, path: path}
+	simple, ok := ctxt.simpleTypes[typeName]
+	if !ok {
+		simple = newSimpleType(typeName)
+		simple.base = typeName
+	}
+	col.ctype = sqlType(simple, ctxt)
+	table.add(col)
+	// the check refers to the column by its final name
+	col = table.columns[len(table.columns)-1]
+	col.check = sqlCheck(col.name, simple, ctxt)
+	table.columns[len(table.columns)-1] = col
+}
+
+// the column type for a simple type
+func sqlType(simple *simpleType, ctxt *context) string {
+	pg := ctxt.sqlDialect == sqlPostgres
+	switch builtinKind(simple.base) {
+	case kindBoolean:
+		if pg {
+			return "BOOLEAN"
+		}
+		return "INTEGER"
+	case kindInteger:
+		if pg {
+			return "BIGINT"
+		}
+		return "INTEGER"
+	case kindFloat:
+		if pg {
+			return "DOUBLE PRECISION"
+		}
+		return "REAL"
+	case kindDecimal:
+		// without fractionDigits the scale is 0, i.e. whole numbers
+		total, fraction := decimalDigits(simple)
+		switch {
+		case total < 1:
+			return "NUMERIC"
+		case fraction < 0:
+			return fmt.Sprintf("NUMERIC(%d)", total)
+		}
+		return fmt.Sprintf("NUMERIC(%d, %d)", total, fraction)
+	case kindDate:
+		if pg {
+			return "DATE"
+		}
+		return "TEXT"
+	case kindDateTime:
+		if pg {
+			return "TIMESTAMP WITH TIME ZONE"
+		}
+		return "TEXT"
+	case kindTime:
+		if pg {
+			return "TIME"
+		}
+		return "TEXT"
+	case kindBinary:
+		if pg {
+			return "BYTEA"
+		}
+		return "BLOB"
+	}
+	max := simple.maxLength
+	if simple.length > -1 {
+		max = simple.length
+	}
+	for _, v := range simple.enum {
+		if len(v) > max {
+			max = len(v)
+		}
+	}
+	if max < 1 || !pg {
+		return "TEXT"
+	}
+	return fmt.Sprintf("VARCHAR(%d)", max)
+}
+
+// the CHECK constraint of a column, if any: the enumeration, and for
+// SQLite, which ignores the length of a VARCHAR, the length and booleans
+func sqlCheck(name string, simple *simpleType, ctxt *context) string {
+	if len(simple.enum) > 0 {
+		values := make([]string, 0, len(simple.enum))
+		for _, v := range simple.enum {
+			values = append(values, "'"+strings.Replace(v, "'", "''", -1)+"'")
+		}
+		return fmt.Sprintf("%s IN (%s)", name, strings.Join(values, ", "))
+	}
+	if ctxt.sqlDialect == sqlPostgres {
+		return ""
+	}
+	if builtinKind(simple.base) == kindBoolean {
+		return fmt.Sprintf("%s IN (0, 1)", name)
+	}
+	max := simple.maxLength
+	if simple.length > -1 {
+		max = simple.length
+	}
+	if max > 0 && builtinKind(simple.base) == kindString {
+		return fmt.Sprintf("length(%s) <= %d", name, max)
+	}
+	return ""
+}
+
+// write the CREATE TABLE of a table
+func writeSqlTable(w io.Writer, table *sqlTable, ctxt *context) {
+	lines := make([]string, 0, len(table.columns)+3)
+	comments := make([]string, 0, len(table.columns)+3)
+	if ctxt.sqlDialect == sqlPostgres {
+		lines = append(lines, "id BIGSERIAL PRIMARY KEY")
+	} else {
+		lines = append(lines, "id INTEGER PRIMARY KEY")
+	}
+	comments = append(comments, "")
+	if table.parent != nil {
+		fk := "BIGINT"
+		if ctxt.sqlDialect == sqlSqlite {
+			fk = "INTEGER"
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s_id %s NOT NULL REFERENCES %s (id) ON DELETE CASCADE", table.parent.name, fk, table.parent.name),
+			"seq INTEGER NOT NULL")
+		comments = append(comments, "", "the position in "+table.parent.name)
+	}
+	for _, col := range table.columns {
+		line := col.name + " " + col.ctype
+		if col.notNull {
+			line += " NOT NULL"
+		}
+		if col.check != "" {
+			line += " CHECK (" + col.check + ")"
+		}
+		lines = append(lines, line)
+		comments = append(comments, col.path)
+	}
+
+	fmt.Fprintf(w, "\n-- %s (%s)\n", table.path, table.xsdType)
+	fmt.Fprintf(w, "CREATE TABLE %s (\n", table.name)
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			line += ","
+		}
+		if comments[i] != "" {
+			line += " -- " + comments[i]
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintf(w, ");\n")
+	if table.parent != nil {
+		fmt.Fprintf(w, "CREATE INDEX %s_%s_id ON %s (%s_id);\n", table.name, table.parent.name, table.name, table.parent.name)
+	}
+}
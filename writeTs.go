@@ -0,0 +1,294 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// writeTs
+// Take the populated data structures and output TypeScript types for the
+// JSON of the spec, which suit a .ts or a .d.ts file
+// complex types are interfaces, or unions of the alternatives of a
+// choice; enumerations are unions of string literals, and other simple
+// types are aliases of string, number or boolean
+// the XSD documentation and the comments of the mask file are JSDoc
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// a property name that needn't be quoted
+var regTsIdentifier = regexp.MustCompile("^[A-Za-z_$][A-Za-z0-9_$]*$")
+
+// a property of an interface
+type tsProp struct {
+	name     string
+	tstype   string
+	optional bool
+	doc      []string
+}
+
+// entry point for writing
+func writeTs(f io.Writer, ctxt *context) {
+	w := bufio.NewWriter(f)
+	files := make([]string, 0, len(ctxt.messages))
+	for _, msg := range ctxt.messages {
+		files = append(files, msg.inFileBase)
+	}
+	fmt.Fprintf(w, "// Generated by xsd2oas from %s; do not edit\n", strings.Join(files, ", "))
+	comments := maskComments(ctxt)
+	for _, name := range includedTypes(ctxt) {
+		if simple, ok := ctxt.simpleTypes[name]; ok {
+			tsSimple(w, simple, ctxt)
+			continue
+		}
+		tsComplex(w, ctxt.complexTypes[name], comments[name], ctxt)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// the comments of the mask files, by type and tag
+// the comment of a path is for its last element, e.g. IBAN in
+// AccountIdentification4Choice
+func maskComments(ctxt *context) map[string]map[string][]string {
+	comments := make(map[string]map[string][]string)
+	for _, msg := range ctxt.messages {
+		for _, line := range msg.mask {
+			if line.comment == "" {
+				continue
+			}
+			tags := strings.Split(strings.Trim(line.path, "/"), "/")
+			if len(tags) > 0 && tags[0] == msg.root.name {
+				tags = tags[1:]
+			}
+			if len(tags) < 2 {
+				continue
+			}
+			owner := messageRoot(msg, ctxt)
+			for i, tag := range tags[1:] {
+				cmplx, ok := ctxt.complexTypes[owner]
+				if !ok {
+					break
+				}
+				var found *element
+				for _, el := range cmplx.elems {
+					if el.name == tag {
+						found = el
+					}
+				}
+				if found == nil {
+					break
+				}
+				if i == len(tags)-2 {
+					if comments[owner] == nil {
+						comments[owner] = make(map[string][]string)
+					}
+					comments[owner][tag] = appendNew(comments[owner][tag], line.comment)
+				}
+				owner = found.etype
+			}
+		}
+	}
+	return comments
+}
+
+// add a string to a list, unless it's there already
+func appendNew(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// a property name, quoted if need be, e.g. "@Ccy"
+func tsName(name string) string {
+	if regTsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// the TypeScript type for an XSD builtin type
+func tsScalar(base string, ctxt *context) string {
+	switch builtinKind(base) {
+	case kindBoolean:
+		return "boolean"
+	case kindInteger, kindFloat:
+		return "number"
+	case kindDecimal:
+		if ctxt.decimalMode == decimalString {
+			return "string"
+		}
+		return "number"
+	}
+	return "string"
+}
+
+// the TypeScript type for an XSD type
+func tsType(name string, ctxt *context) string {
+	if _, ok := ctxt.simpleTypes[name]; ok {
+		return name
+	}
+	if _, ok := ctxt.complexTypes[name]; ok {
+		return name
+	}
+	return tsScalar(name, ctxt)
+}
+
+// the value of a simple type: a union of its enumeration, or a scalar
+func tsValue(simple *simpleType, ctxt *context) string {
+	if len(simple.enum) == 0 {
+		return tsScalar(simple.base, ctxt)
+	}
+	values := make([]string, 0, len(simple.enum))
+	for _, v := range simple.enum {
+		values = append(values, fmt.Sprintf("%q", v))
+	}
+	return strings.Join(values, " | ")
+}
+
+// write a JSDoc comment, if there's anything to say
+func tsDoc(w io.Writer, indent string, lines []string) {
+	text := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l != "" {
+			text = append(text, strings.Replace(l, "*/", "*\\/", -1))
+		}
+	}
+	switch len(text) {
+	case 0:
+	case 1:
+		fmt.Fprintf(w, "%s/** %s */\n", indent, text[0])
+	default:
+		fmt.Fprintf(w, "%s/**\n", indent)
+		for _, t := range text {
+			fmt.Fprintf(w, "%s * %s\n", indent, t)
+		}
+		fmt.Fprintf(w, "%s */\n", indent)
+	}
+}
+
+// write the properties of an interface or alternative
+func tsProps(w io.Writer, indent string, props []tsProp) {
+	for _, p := range props {
+		tsDoc(w, indent, p.doc)
+		opt := ""
+		if p.optional {
+			opt = "?"
+		}
+		fmt.Fprintf(w, "%s%s%s: %s;\n", indent, tsName(p.name), opt, p.tstype)
+	}
+}
+
+// a simple type: an alias, or an interface if it has attributes
+// with -attrs flatten, the attributes are with the parent, so it's the value
+func tsSimple(w io.Writer, simple *simpleType, ctxt *context) {
+	fmt.Fprintf(w, "\n")
+	tsDoc(w, "", []string{simple.doc})
+	if len(simple.attrs) == 0 || ctxt.attrMap.flatten() {
+		fmt.Fprintf(w, "export type %s = %s;\n", simple.name, tsValue(simple, ctxt))
+		return
+	}
+	props := []tsProp{{name: ctxt.attrMap.valueName(), tstype: tsValue(simple, ctxt)}}
+	for _, attr := range simple.attrs {
+		props = append(props, tsProp{
+			name:     attrPropName(simple.name, attr.name, ctxt),
+			tstype:   tsType(attr.atype, ctxt),
+			optional: !attr.required,
+		})
+	}
+	fmt.Fprintf(w, "export interface %s {\n", simple.name)
+	tsProps(w, "  ", props)
+	fmt.Fprintf(w, "}\n")
+}
+
+// a complex type: an interface, or for a choice a union with an
+// alternative for each element, which rules out the others
+func tsComplex(w io.Writer, cmplx *complexType, comments map[string][]string, ctxt *context) {
+	attrs := make([]tsProp, 0, len(cmplx.attrs))
+	for _, attr := range cmplx.attrs {
+		attrs = append(attrs, tsProp{
+			name:     attrPropName(cmplx.name, attr.name, ctxt),
+			tstype:   tsType(attr.atype, ctxt),
+			optional: !attr.required,
+		})
+	}
+	choice := cmplx.etype == "choice"
+	// the properties of each element: it, and any flattened attributes
+	elems := make([][]tsProp, 0, len(cmplx.elems))
+	for _, el := range cmplx.elems {
+		if !el.include {
+			continue
+		}
+		tstype := tsType(el.etype, ctxt)
+		if el.maxOccurs > 1 {
+			tstype += "[]"
+		}
+		optional := el.minOccurs == 0 && !choice
+		props := []tsProp{{
+			name:     propName(cmplx.name, el.name, ctxt),
+			tstype:   tstype,
+			optional: optional,
+			doc:      append([]string{el.doc}, comments[el.name]...),
+		}}
+		if simple, ok := flatAttrType(el, ctxt); ok {
+			for _, attr := range simple.attrs {
+				props = append(props, tsProp{
+					name:     flatPropName(cmplx.name, el.name, attr.name, ctxt),
+					tstype:   tsType(attr.atype, ctxt),
+					optional: optional || !attr.required,
+				})
+			}
+		}
+		elems = append(elems, props)
+	}
+
+	fmt.Fprintf(w, "\n")
+	tsDoc(w, "", []string{cmplx.doc})
+	if !choice || len(elems) == 0 {
+		fmt.Fprintf(w, "export interface %s {\n", cmplx.name)
+		tsProps(w, "  ", attrs)
+		for _, props := range elems {
+			tsProps(w, "  ", props)
+		}
+		fmt.Fprintf(w, "}\n")
+		return
+	}
+	fmt.Fprintf(w, "export type %s =\n", cmplx.name)
+	for i, props := range elems {
+		fmt.Fprintf(w, "  | {\n")
+		tsProps(w, "      ", attrs)
+		tsProps(w, "      ", props)
+		for j, others := range elems {
+			if j != i {
+				fmt.Fprintf(w, "      %s?: never;\n", tsName(others[0].name))
+			}
+		}
+		end := "\n"
+		if i == len(elems)-1 {
+			end = ";\n"
+		}
+		fmt.Fprintf(w, "    }%s", end)
+	}
+}
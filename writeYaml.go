@@ -15,193 +15,372 @@
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
 
 // writeYaml
-// Take the populated data structures and output OAS in Yaml
+// Take the populated data structures, build the OAS document and output it as YAML or JSON
 
 package main
 
 import (
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 const tsz = 2 // tab size
-var regupr *regexp.Regexp
 
-// default fixup does nothing
-var fixup = func(a string) string {
-	return a
-}
-
-func init() {
-	regupr = regexp.MustCompile("[a-z]") // compiled regexp tp find uppercase
-}
+// OpenAPI versions
+const (
+	oas20 = "2.0" // Swagger
+	oas30 = "3.0"
+	oas31 = "3.1"
+)
 
 // entry point for writing
 func writeYaml(f io.Writer, ctxt *context) {
 
-	if ctxt.fixUppercase {
-		// convert param name that are all upppercase to camelcase
-		fixup = func(name string) string {
-			if regupr.FindStringIndex(name) == nil {
-				name = name[0:1] + strings.ToLower(name[1:])
-			}
-			return name
-		}
+	doc := buildHdrs(ctxt)
+	buildComponents(doc, ctxt)
+	if ctxt.hdrTemplate != "" {
+		applyTemplate(doc, ctxt)
 	}
+	if err := encodeDoc(f, doc, ctxt.format); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
 
-	writeHdrs(f, ctxt, 0)
-	writeComponents(f, ctxt, 0)
+// the reference to a named schema
+func schemaRef(name string, ctxt *context) *docMap {
+	prefix := "#/components/schemas/"
+	switch {
+	case ctxt.refPrefix != "":
+		prefix = ctxt.refPrefix
+	case ctxt.oasVersion == oas20:
+		prefix = "#/definitions/"
+	}
+	return newDocMap().set("$ref", prefix+name)
 }
 
-// write the name of an element or type
-func writeName(n named, f io.Writer, ctxt *context, indent int) {
-	inPrintf(f, indent, "%s:\n", n.getName())
+// note a rule that the schema can't express
+// 3.1 schemas can hold $comment, otherwise it's a YAML comment on the key
+func note(m *docMap, key string, ctxt *context, format string, v ...interface{}) {
+	if ctxt.oasVersion != oas31 {
+		m.comment(key, format, v...)
+		return
+	}
+	text := fmt.Sprintf(format, v...)
+	if old, ok := m.get("$comment"); ok {
+		text = old.(string) + "; " + text
+	}
+	m.set("$comment", text)
 }
 
-// write an element
+// set an exclusive bound ("minimum" or "maximum")
+// 3.0 qualifies the inclusive keyword with a boolean, 3.1 has a numeric keyword
+func exclusiveBound(m *docMap, key string, bound interface{}, ctxt *context) {
+	exclusive := "exclusive" + strings.ToUpper(key[:1]) + key[1:]
+	if ctxt.oasVersion == oas31 {
+		m.set(exclusive, bound)
+		return
+	}
+	m.set(key, bound)
+	m.set(exclusive, true)
+}
+
+// the schema of an element, whose property is called name
 // if multiple occurrences are allowed, make it an array of items
 // of the specified type
-func writeElement(el *element, f io.Writer, ctxt *context, indent int) {
-	name := fixup(el.getName())
-
+// if it is nillable, null is allowed as well
+func elementSchema(el *element, name string, ctxt *context) *docMap {
+	m := schemaRef(el.etype, ctxt)
+	if el.nillable {
+		m = nullable(m, ctxt)
+	}
 	if el.maxOccurs > 1 {
-		inPrintf(f, indent, "%s:\n", name)
-		inPrintf(f, indent+tsz, "type: array\n")
-		inPrintf(f, indent+tsz, "items:\n")
-		inPrintf(f, indent+tsz+tsz, "$ref: '#/components/schemas/%s'\n", el.etype)
-	} else {
-		inPrintf(f, indent, "%s:\n", name)
-		inPrintf(f, indent+tsz, "$ref: '#/components/schemas/%s'\n", el.etype)
+		m = newDocMap().
+			set("type", "array").
+			set("items", m)
 	}
+	m = addXml(m, xmlElement(el, name), ctxt)
+	return xmlTag(m, el.name, name, ctxt)
 }
 
-// write the body of a simple type
-// if it has attributes, turn it into an object
+// allow null as well as the referenced schema
+func nullable(ref *docMap, ctxt *context) *docMap {
+	if ctxt.oasVersion == oas31 {
+		return newDocMap().set("anyOf", docList{ref, newDocMap().set("type", "null")})
+	}
+	// siblings of $ref are ignored in 3.0
+	if ctxt.oasVersion == oas20 {
+		return newDocMap().set("allOf", docList{ref}).set("x-nullable", true)
+	}
+	return newDocMap().set("allOf", docList{ref}).set("nullable", true)
+}
+
+// the body of a simple type
+// if it has attributes, turn it into an object (unless they are flattened)
 // the value element represents the base type
-// each attribute forms a separate element named @Attributename
-func writeSimpleBody(simple *simpleType, f io.Writer, ctxt *context, indent int) {
-	if len(simple.attrs) > 0 {
-		inPrintf(f, indent, "type: object\n")
-		inPrintf(f, indent, "properties:\n")
-		inPrintf(f, indent+tsz, "\"value\":\n")
-		writeSimpleProperties(simple, f, ctxt, indent+tsz+tsz)
-		required := writeAttrs(simple, f, ctxt, indent+tsz)
-		inPrintf(f, indent, "required: %s\n", arrayString(required))
-		inPrintf(f, indent, "additionalProperties: false\n")
-	} else {
-		writeSimpleProperties(simple, f, ctxt, indent)
+// each attribute forms a separate element, named by the attribute mapping
+// e.g. @Attributename
+func simpleBody(simple *simpleType, ctxt *context) *docMap {
+	if len(simple.attrs) == 0 || ctxt.attrMap.flatten() {
+		return simpleProperties(simple, ctxt)
 	}
+	m := newDocMap()
+	m.set("type", "object")
+	props := m.child("properties")
+	value := ctxt.attrMap.valueName()
+	props.set(value, addXml(simpleProperties(simple, ctxt), xmlText(), ctxt))
+	required := append([]string{value}, attrSchemas(simple, props, "", nil, ctxt)...)
+	m.set("required", flowStrings(required))
+	m.set("additionalProperties", false)
+	return m
 }
 
-// write the properties of a simple type
-func writeSimpleProperties(simple *simpleType, f io.Writer, ctxt *context, indent int) {
+// the properties of a simple type
+func simpleProperties(simple *simpleType, ctxt *context) *docMap {
+	m := newDocMap()
 	jtype, mapped := mapTypename(simple.base)
-	inPrintf(f, indent, "type: %s\n", jtype)
+	decimal := isDecimal(simple)
+	if decimal && ctxt.decimalMode == decimalString {
+		jtype = "string"
+	}
+	m.set("type", jtype)
 	if mapped {
-		inPrintf(f, indent, "# XML datatype was %s\n", simple.base)
+		m.comment("type", "XML datatype was %s", simple.base)
 	}
+	if decimal {
+		decimalProperties(simple, m, ctxt)
+	} else {
+		restrictions(simple, m, ctxt)
+	}
+	if ctxt.oasVersion == oas31 {
+		m.set("examples", docList{sampleValue(simple, ctxt)})
+	}
+	return m
+}
+
+// the restrictions on a simple type
+func restrictions(simple *simpleType, m *docMap, ctxt *context) {
 	// string constraints
 	if simple.minLength > -1 {
-		inPrintf(f, indent, "minLength: %d\n", simple.minLength)
+		m.set("minLength", simple.minLength)
 	}
 	if simple.maxLength > -1 {
-		inPrintf(f, indent, "maxLength: %d\n", simple.maxLength)
+		m.set("maxLength", simple.maxLength)
 	}
 	if simple.length > -1 {
-		inPrintf(f, indent, "minLength: %d\n", simple.length)
-		inPrintf(f, indent, "maxLength: %d\n", simple.length)
+		m.set("minLength", simple.length)
+		m.set("maxLength", simple.length)
 	}
 	if len(simple.enum) > 0 {
-		inPrintf(f, indent, "enum: %s\n", arrayString(simple.enum))
+		m.set("enum", flowStrings(simple.enum))
 	}
 	if simple.pattern != "" {
-		// double all slashes to make valid JSON escapes
-		escaped := strings.Replace(simple.pattern, "\\", "\\\\", -1)
-		inPrintf(f, indent, "pattern: '%s'\n", escaped)
+		patt, diags := translatePattern(simple.pattern, ecmaRegex)
+		m.set("pattern", patt)
+		for _, d := range diags {
+			fmt.Printf("Pattern for %s: %s\n", simple.name, d)
+			note(m, "pattern", ctxt, "XML pattern %s not translated exactly: %s", simple.pattern, d)
+		}
 	}
 	// number constraints
 	if simple.minInclusive > -1 {
-		inPrintf(f, indent, "minimum: %d\n", simple.minInclusive)
+		m.set("minimum", simple.minInclusive)
 	}
 	if simple.minExclusive > -1 {
-		inPrintf(f, indent, "exclusiveMinimum: %d\n", simple.minExclusive)
+		exclusiveBound(m, "minimum", simple.minExclusive, ctxt)
 	}
 	if simple.maxInclusive > -1 {
-		inPrintf(f, indent, "maximum: %d\n", simple.maxInclusive)
+		m.set("maximum", simple.maxInclusive)
 	}
 	if simple.maxExclusive > -1 {
-		inPrintf(f, indent, "exclusiveMaximum: %d\n", simple.maxExclusive)
+		exclusiveBound(m, "maximum", simple.maxExclusive, ctxt)
 	}
 	// JSON schema can't handle these rules
 	if simple.totalDigits > -1 {
-		inPrintf(f, indent, "# XML specified totalDigits=%d\n", simple.totalDigits)
+		note(m, "type", ctxt, "XML specified totalDigits=%d", simple.totalDigits)
 	}
 	if simple.fractionDigits > -1 {
-		inPrintf(f, indent, "# XML specified fractionDigits=%d\n", simple.fractionDigits)
+		note(m, "type", ctxt, "XML specified fractionDigits=%d", simple.fractionDigits)
 	}
 	if simple.whiteSpace != "" {
-		inPrintf(f, indent, "# XML specified whiteSpace=%s\n", simple.whiteSpace)
+		note(m, "type", ctxt, "XML specified whiteSpace=%s", simple.whiteSpace)
 	}
 }
 
-// write the file headers
-func writeHdrs(f io.Writer, ctxt *context, indent int) {
-	servers := []string{"https://example.com"}
-	// when := time.Now().Format(time.RFC1123)
-	if ctxt.servers != "" {
-		r := regexp.MustCompile("\\s*,\\s*")
-		servers = r.Split(ctxt.servers, -1)
-	}
-	endpoint := "/" + ctxt.outFileBase
-	if ctxt.endpoint != "" {
-		endpoint = ctxt.endpoint
-		if endpoint[0:0] != "/" {
-			endpoint = "/" + endpoint
+// the properties of a decimal
+// as a string, the digit rules and sign become a pattern
+// as a number, they become multipleOf and maximum
+func decimalProperties(simple *simpleType, m *docMap, ctxt *context) {
+	if ctxt.decimalMode == decimalString {
+		m.set("pattern", decimalPattern(simple))
+		// the pattern checks bounds of 0, but can't check other values
+		if simple.minInclusive > 0 {
+			note(m, "pattern", ctxt, "XML specified minInclusive=%d", simple.minInclusive)
+		}
+		if simple.minExclusive > 0 {
+			note(m, "pattern", ctxt, "XML specified minExclusive=%d", simple.minExclusive)
+		}
+		if simple.maxInclusive > 0 {
+			note(m, "pattern", ctxt, "XML specified maxInclusive=%d", simple.maxInclusive)
+		}
+		if simple.maxExclusive > 0 {
+			note(m, "pattern", ctxt, "XML specified maxExclusive=%d", simple.maxExclusive)
 		}
+		return
+	}
+	if step := decimalMultipleOf(simple); step != "" {
+		m.set("multipleOf", docNumber(step))
 	}
-	title := ctxt.outFileBase
-	if ctxt.title != "" {
-		title = ctxt.title
+	if simple.minInclusive > -1 {
+		m.set("minimum", simple.minInclusive)
 	}
-	rootType := ctxt.complexTypes[ctxt.root.getName()]
+	if simple.minExclusive > -1 {
+		exclusiveBound(m, "minimum", simple.minExclusive, ctxt)
+	}
+	// the tighter of the bound and the largest value the digits allow
+	max := decimalMaximum(simple)
+	digitMax := math.Inf(1)
+	if max != "" {
+		digitMax, _ = strconv.ParseFloat(max, 64)
+	}
+	switch {
+	case simple.maxInclusive > -1 && float64(simple.maxInclusive) <= digitMax:
+		m.set("maximum", simple.maxInclusive)
+	case simple.maxExclusive > -1 && float64(simple.maxExclusive) <= digitMax:
+		exclusiveBound(m, "maximum", simple.maxExclusive, ctxt)
+	case max != "":
+		m.set("maximum", docNumber(max))
+	}
+	if max != "" && decimalSigned(simple) {
+		m.set("minimum", docNumber("-"+max))
+	}
+}
 
-	var hdr string
-	if ctxt.hdrTemplate != "" {
-		hdr = ctxt.hdrTemplate
-	} else {
-		hdr = defaultHeader()
+// build the document headers: everything before components
+func buildHdrs(ctxt *context) *docMap {
+	servers := docServers(ctxt)
+	title := docTitle(ctxt)
+	var doc *docMap
+	switch {
+	case ctxt.api != nil && len(ctxt.api.Operations) > 0:
+		doc = apiHeaders(title, servers, ctxt)
+	case ctxt.oasVersion == oas20:
+		doc = swaggerHeader(title, servers, ctxt)
+	default:
+		doc = defaultHeader(title, servers, ctxt)
 	}
+	buildWebhooks(doc, ctxt)
+	return doc
+}
 
-	urls := ""
-	for _, s := range servers {
-		urls += "  - url: " + s + "\n"
+// the title of the document
+// the API definition gives it, unless it's on the command line;
+// otherwise, the ISO 20022 message identifiers
+func docTitle(ctxt *context) string {
+	switch {
+	case ctxt.title != "":
+		return ctxt.title
+	case ctxt.api != nil && ctxt.api.Title != "":
+		return ctxt.api.Title
+	}
+	if title := isoTitle(ctxt); title != "" {
+		return title
+	}
+	return ctxt.outFileBase
+}
+
+// the version of the document
+// the API definition gives it; otherwise, the ISO 20022 message version
+func docVersion(ctxt *context) string {
+	if ctxt.api != nil && ctxt.api.Version != "" {
+		return ctxt.api.Version
+	}
+	if version := isoVersion(ctxt); version != "" {
+		return version
+	}
+	return "0.1"
+}
+
+// the server URLs
+// the API definition gives them, unless they're on the command line
+func docServers(ctxt *context) []string {
+	switch {
+	case ctxt.servers != "":
+		r := regexp.MustCompile("\\s*,\\s*")
+		return r.Split(ctxt.servers, -1)
+	case ctxt.api != nil && len(ctxt.api.Servers) > 0:
+		return ctxt.api.Servers
 	}
+	return []string{"https://example.com"}
+}
 
-	r := strings.NewReplacer(
-		"$TITLE", title,
-		"$PATH", endpoint,
-		"$URLS", urls,
-		"$ROOT", rootType.elems[0].etype)
-	hdr = r.Replace(hdr)
-	fmt.Fprint(f, hdr)
+// the name of the message type: the type of the root element's child
+func messageRoot(msg *message, ctxt *context) string {
+	rootType := ctxt.complexTypes[msg.root.etype]
+	return rootType.elems[0].etype
 }
 
-// write all the component definitions
-func writeComponents(f io.Writer, ctxt *context, indent int) {
-	inPrintf(f, indent, "# ---Component definitions---\n")
-	inPrintf(f, indent, "components:\n")
-	writeSchemas(f, ctxt, indent+tsz)
+// the tag of the message in the document, e.g. FIToFICstmrCdtTrf
+func messageRootTag(msg *message, ctxt *context) string {
+	return ctxt.complexTypes[msg.root.etype].elems[0].name
 }
 
-// write all the schema definitions
-func writeSchemas(f io.Writer, ctxt *context, indent int) {
+// the path to the endpoint for a message
+// by default, the ISO 20022 message identifier; otherwise the output
+// file name, or the XSD name if there are several
+func messageEndpoint(msg *message, ctxt *context) string {
+	endpoint := msg.endpoint
+	switch {
+	case endpoint != "":
+	case msg.iso != nil:
+		endpoint = msg.iso.String()
+	case len(ctxt.messages) == 1:
+		endpoint = ctxt.outFileBase
+	default:
+		endpoint = strings.TrimSuffix(msg.inFileBase, filepath.Ext(msg.inFileBase))
+	}
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	return endpoint
+}
 
-	inPrintf(f, indent, "schemas:\n\n")
+// the operation for a message, or nil if its endpoint is already used
+func messagePath(paths *docMap, msg *message, ctxt *context) *docMap {
+	endpoint := messageEndpoint(msg, ctxt)
+	if _, ok := paths.get(endpoint); ok {
+		fmt.Printf("Warning: endpoint %s of %s already used, no path written\n", endpoint, msg.inFileBase)
+		return nil
+	}
+	return paths.child(endpoint).child("put")
+}
 
-	// merge simple and complex together and sort them
+// add all the component definitions
+// Swagger 2.0 has definitions instead
+func buildComponents(doc *docMap, ctxt *context) {
+	if ctxt.oasVersion == oas20 {
+		doc.set("definitions", buildSchemas(ctxt))
+		addErrorSchema(doc.child("definitions"), ctxt)
+		doc.comment("definitions", "---Schema definitions---")
+		sharedComponents(doc, ctxt)
+		return
+	}
+	comps := doc.child("components")
+	doc.comment("components", "---Component definitions---")
+	comps.set("schemas", buildSchemas(ctxt))
+	addErrorSchema(comps.child("schemas"), ctxt)
+	sharedComponents(comps, ctxt)
+}
+
+// the included types, simple and complex together, sorted
+func includedTypes(ctxt *context) []string {
 	cmb := make([]string, 0)
 	for _, simple := range ctxt.simpleTypes {
 		if simple.include {
@@ -214,37 +393,45 @@ func writeSchemas(f io.Writer, ctxt *context, indent int) {
 		}
 	}
 	sort.Strings(cmb)
+	return cmb
+}
+
+// build all the schema definitions
+func buildSchemas(ctxt *context) *docMap {
+
+	schemas := newDocMap()
+
+	cmb := includedTypes(ctxt)
+	// the XML body is wrapped in the root element
+	if ctxt.xml {
+		for _, msg := range ctxt.messages {
+			cmb = append(cmb, msg.root.etype)
+		}
+	}
+	sort.Strings(cmb)
 
 	for _, nm := range cmb {
+		var m *docMap
 		if simple, ok := ctxt.simpleTypes[nm]; ok {
-			writeSimple(simple, f, ctxt, indent+tsz)
+			m = simpleBody(simple, ctxt)
 		} else {
-			writeComplex(ctxt.complexTypes[nm], f, ctxt, indent+tsz)
+			m = complexBody(ctxt.complexTypes[nm], ctxt)
 		}
+		schemas.set(nm, addXml(m, xmlSchema(nm, ctxt), ctxt))
 	}
+	return schemas
 }
 
-// write a simple type definition
-func writeSimple(simple *simpleType, f io.Writer, ctxt *context, indent int) {
-	writeName(simple, f, ctxt, indent)
-	writeSimpleBody(simple, f, ctxt, indent+tsz)
-}
-
-// write a complex type definition
-func writeComplex(cmplx *complexType, f io.Writer, ctxt *context, indent int) {
-	writeName(cmplx, f, ctxt, indent)
-	writeComplexBody(cmplx, f, ctxt, indent+tsz)
-}
-
-// write the body of a complex type
-func writeComplexBody(cmplx *complexType, f io.Writer, ctxt *context, indent int) {
+// the body of a complex type
+func complexBody(cmplx *complexType, ctxt *context) *docMap {
 	// if it's based on simple, do simple body
 	if cmplx.simpleBase != nil {
 		fmt.Printf("Doing simple body for %s: %v\n", cmplx.name, *cmplx.simpleBase)
-		writeSimpleBody(cmplx.simpleBase, f, ctxt, indent+tsz)
-		return
+		return simpleBody(cmplx.simpleBase, ctxt)
 	}
 
+	m := newDocMap()
+	m.set("type", "object")
 	switch cmplx.etype {
 	case "choice":
 		// XSD choice maps to YAML schema thus:
@@ -257,138 +444,172 @@ func writeComplexBody(cmplx *complexType, f io.Writer, ctxt *context, indent int
 		//   "oneOf":
 		//   - required: [Pty]
 		//   - required: [Agt]
-		inPrintf(f, indent, "type: object\n")
-		inPrintf(f, indent, "properties:\n")
+		props := m.child("properties")
+		oneOf := make(docList, 0)
 		for _, el := range cmplx.elems {
 			if el.include {
-				writeElement(el, f, ctxt, indent+tsz)
+				name := propName(cmplx.name, el.name, ctxt)
+				props.set(name, elementSchema(el, name, ctxt))
+				flatAttrSchemas(el, cmplx.name, props, ctxt)
+				oneOf = append(oneOf, newDocMap().set("required", flowStrings([]string{name})))
 			}
 		}
-		inPrintf(f, indent, "oneOf:\n")
-		for _, el := range cmplx.elems {
-			if el.include {
-				inPrintf(f, indent, "- required: [%v]\n", fixup(el.getName()))
-			}
+		if ctxt.oasVersion == oas20 {
+			// Swagger 2.0 has no oneOf, so validators won't enforce the choice
+			fmt.Printf("Warning: choice %s written as x-oneOf, Swagger 2.0 has no oneOf\n", cmplx.name)
+			m.set("x-oneOf", oneOf)
+			m.comment("x-oneOf", "XSD choice: exactly one of these properties must be present")
+		} else {
+			m.set("oneOf", oneOf)
 		}
 
 	default:
-		inPrintf(f, indent, "type: object\n")
 		if len(cmplx.attrs)+len(cmplx.elems) > 0 {
-			inPrintf(f, indent, "properties:\n")
+			props := m.child("properties")
+			required := make([]string, 0)
 			if len(cmplx.attrs) > 0 {
 				fmt.Printf("Doing attrs for complex %s\n", cmplx.name)
-				writeAttrs(cmplx, f, ctxt, indent+tsz)
+				required = append(required, attrSchemas(cmplx, props, "", nil, ctxt)...)
 			}
-			required := make([]string, 0)
 			for _, el := range cmplx.elems {
 				if el.include {
-					writeElement(el, f, ctxt, indent+tsz)
+					name := propName(cmplx.name, el.name, ctxt)
+					props.set(name, elementSchema(el, name, ctxt))
+					flatRequired := flatAttrSchemas(el, cmplx.name, props, ctxt)
 					if el.minOccurs != 0 {
-						required = append(required, fixup(el.getName()))
+						required = append(required, name)
+						required = append(required, flatRequired...)
 					}
 				}
 			}
 			if len(required) > 0 {
-				inPrintf(f, indent, "required: %s\n", arrayString(required))
+				m.set("required", flowStrings(required))
 			}
 		}
 	}
 	if !cmplx.anyFlag {
-		inPrintf(f, indent, "additionalProperties: false\n")
+		m.set("additionalProperties", false)
 	} else {
-		inPrintf(f, indent, "# XSD allows 'any', so properties not restricted\n")
+		note(m, "type", ctxt, "XSD allows 'any', so properties not restricted")
 	}
+	return m
 }
 
-func writeAttrs(attd attributed, f io.Writer, ctxt *context, indent int) []string {
+// add the attribute properties, returning the required property names
+// el is the element if the attributes are flattened into parent,
+// or nil if they belong to this object
+func attrSchemas(attd attributed, props *docMap, parent string, el *element, ctxt *context) []string {
 	attrs := attd.getAttrs()
-	required := []string{"value"}
+	required := make([]string, 0)
 	for _, attr := range attrs {
+		var name string
+		if el == nil {
+			name = attrPropName(attd.getName(), attr.name, ctxt)
+		} else {
+			name = flatPropName(parent, el.name, attr.name, ctxt)
+		}
 		if attr.required {
-			required = append(required, "@"+attr.name)
+			required = append(required, name)
 		}
-		inPrintf(f, indent, "'@%s':\n", attr.name)
+		m := newDocMap()
 		// atype must be either builtin or simple ...
 		if _, ok := ctxt.simpleTypes[attr.atype]; ok {
-			inPrintf(f, indent+tsz, "$ref: '#/components/schemas/%s'\n", attr.atype)
+			m = schemaRef(attr.atype, ctxt)
 		} else {
-			inPrintf(f, indent+tsz, "type: %s\n", attr.atype)
+			m.set("type", attr.atype)
 		}
 		if attr.adefault != "" {
-			inPrintf(f, indent+tsz, "default: \"%s\"\n", attr.adefault)
+			m.set("default", attr.adefault)
 		}
 		if attr.fixed != "" {
-			// fmt.Fprinf(f, ",\n")
-			inPrintf(f, indent+tsz, "# XML specified fixed value %s\n", attr.fixed)
+			if ctxt.oasVersion == oas31 {
+				m.set("const", attr.fixed)
+			} else {
+				m.comment(m.keys[0], "XML specified fixed value %s", attr.fixed)
+			}
+		}
+		if el == nil {
+			m = addXml(m, xmlAttribute(attr), ctxt)
 		}
+		props.set(name, xmlTag(m, attr.name, propName(attd.getName(), attrTag(attr.name), ctxt), ctxt))
 	}
 	return required
 }
 
-// print an arbitrary thing with an indent
-func inPrintf(f io.Writer, indent int, s string, v ...interface{}) (int, error) {
-	var n1, n2 int
-	var err error
-
-	n1, err = fmt.Fprintf(f, "%*s", indent, "")
-	if err == nil {
-		n2, err = fmt.Fprintf(f, s, v...)
+// add the attributes of an element to its parent, if they are flattened
+// returning the required property names
+// if the element repeats, so does each attribute, in step with it
+func flatAttrSchemas(el *element, parent string, props *docMap, ctxt *context) []string {
+	simple, ok := flatAttrType(el, ctxt)
+	if !ok {
+		return nil
 	}
-	if err != nil {
-		fmt.Printf("Write failed: %v\n", err)
+	if el.maxOccurs <= 1 {
+		return attrSchemas(simple, props, parent, el, ctxt)
+	}
+	attrProps := newDocMap()
+	required := attrSchemas(simple, attrProps, parent, el, ctxt)
+	for _, k := range attrProps.keys {
+		arr := newDocMap().set("type", "array").set("items", attrProps.vals[k])
+		arr.comment("type", "one entry for each %s", propName(parent, el.name, ctxt))
+		props.set(k, arr)
 	}
-	return n1 + n2, err
+	return required
 }
 
-func arrayString(strs []string) string {
-	s := "["
-	for _, val := range strs {
-		s += fmt.Sprintf("'%s',", val)
+// the default headers, used if no template is given
+func defaultHeader(title string, servers []string, ctxt *context) *docMap {
+	doc := openapiInfo(title, docVersion(ctxt), servers, ctxt)
+	paths := doc.child("paths")
+	for _, msg := range ctxt.messages {
+		if serverMessage(msg, ctxt) {
+			continue
+		}
+		if put := messagePath(paths, msg, ctxt); put != nil {
+			defaultOperation(put, msg, ctxt)
+		}
 	}
-	s = s[:len(s)-1]
-	s += "]"
-	return s
+	return doc
 }
 
-func defaultHeader() string {
-	return `openapi: 3.0.0
-info:
-  title: '$TITLE'
-  version: '0.1'
-
-servers:
-$URLS
-
-paths:
-  '$PATH':
-    put:
-      requestBody:
-        content:
-          application/json:
-            schema:
-              $ref: '#/components/schemas/$ROOT'
-      responses:
-        '200':
-          description: Happy path
-        '400':
-          description: Bad request (body describes why)
-          content: 
-            application/json:
-              schema:
-                type: object
-                properties:
-                  code:
-                    type: string
-                  message:
-                    type: string
-        '429':
-          description: Too Many Requests
-        '4XX':
-          description: Client Error
-        '504':
-          description: Gateway timeout (server did not respond)
-        '5XX':
-          description: Server Error
-        
-`
+// the start of the document: version, info and servers
+func openapiInfo(title string, version string, servers []string, ctxt *context) *docMap {
+	doc := newDocMap()
+	doc.set("openapi", ctxt.oasVersion+".0")
+	info := doc.child("info").
+		set("title", title).
+		set("version", version)
+	isoInfo(info, ctxt)
+
+	urls := make(docList, 0)
+	for _, s := range servers {
+		urls = append(urls, newDocMap().set("url", s))
+	}
+	doc.set("servers", urls)
+	globalSecurity(doc, ctxt)
+	return doc
+}
+
+// the default operation: put the message
+func defaultOperation(put *docMap, msg *message, ctxt *context) {
+	if params := sharedParameters(ctxt); len(params) > 0 {
+		put.set("parameters", params)
+	}
+	content := put.child("requestBody").child("content")
+	content.child("application/json").set("schema", schemaRef(messageRoot(msg, ctxt), ctxt))
+	if ctxt.xml {
+		content.child("application/xml").set("schema", schemaRef(msg.root.etype, ctxt))
+	}
+
+	responses := put.child("responses")
+	responses.child("200").set("description", "Happy path")
+	badRequest := responses.child("400")
+	badRequest.set("description", "Bad request (body describes why)")
+	badRequest.child("content").child("application/json").set("schema", errorSchema(ctxt))
+	responses.child("429").set("description", "Too Many Requests")
+	responses.child("4XX").set("description", "Client Error")
+	responses.child("504").set("description", "Gateway timeout (server did not respond)")
+	responses.child("5XX").set("description", "Server Error")
+	sharedResponseHeaders(responses, ctxt)
+	defaultCallback(put, ctxt)
 }
@@ -0,0 +1,95 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// xmlObject
+// OAS xml objects, so the spec also describes the XML wire format
+
+package main
+
+// add an xml object to a schema
+func addXml(m *docMap, x *docMap, ctxt *context) *docMap {
+	if !ctxt.xml || x.len() == 0 {
+		return m
+	}
+	m = refSiblings(m, ctxt)
+	m.set("xml", x)
+	return m
+}
+
+// make a schema ready for keywords next to its $ref
+// siblings of $ref are ignored before 3.1, so the $ref is moved into allOf
+func refSiblings(m *docMap, ctxt *context) *docMap {
+	ref, ok := m.get("$ref")
+	if !ok || ctxt.oasVersion == oas31 {
+		return m
+	}
+	w := newDocMap().set("allOf", docList{newDocMap().set("$ref", ref)})
+	w.comments["allOf"] = m.comments["$ref"]
+	for _, k := range m.keys {
+		if k != "$ref" {
+			w.set(k, m.vals[k])
+			w.comments[k] = m.comments[k]
+		}
+	}
+	return w
+}
+
+// the xml object for a named schema: every element is in the target namespace
+// if the messages have different namespaces, it is only given for the root elements
+func xmlSchema(name string, ctxt *context) *docMap {
+	x := newDocMap()
+	namespace := ctxt.namespace
+	for _, msg := range ctxt.messages {
+		if name == msg.root.etype {
+			x.set("name", msg.root.name)
+			namespace = msg.namespace
+		}
+	}
+	if namespace != "" {
+		x.set("namespace", namespace)
+	}
+	if ctxt.xmlPrefix != "" {
+		x.set("prefix", ctxt.xmlPrefix)
+	}
+	return x
+}
+
+// the xml object for an element property
+// only needed if the property isn't named after the element, or for arrays:
+// ISO 20022 repeats the element itself rather than wrapping the list
+func xmlElement(el *element, propName string) *docMap {
+	x := newDocMap()
+	if propName != el.name {
+		x.set("name", el.name)
+	}
+	if el.maxOccurs > 1 {
+		x.set("wrapped", false)
+	}
+	return x
+}
+
+// the xml object for an attribute property
+func xmlAttribute(attr attribute) *docMap {
+	return newDocMap().
+		set("name", attr.name).
+		set("attribute", true)
+}
+
+// the xml object for the value of a simple type with attributes
+// OAS has no way to say this is the text of the element, so use an extension
+func xmlText() *docMap {
+	return newDocMap().set("x-text", true)
+}
@@ -0,0 +1,558 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// xsdDiff
+// the diff command: compare two versions of a message, e.g.
+//
+//	xsd2oas diff pacs.008.001.08.xsd pacs.008.001.09.xsd
+//
+// the elements and attributes are compared by path from the root, e.g.
+// /FIToFICstmrCdtTrf/GrpHdr/MsgId, reporting what was added or removed,
+// changes of cardinality, of type (and types renamed with the same
+// structure), and of facets and enumeration values
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diff output formats
+const (
+	diffText     = "text"
+	diffJson     = "json"
+	diffMarkdown = "markdown"
+)
+
+// kinds of change, in the order they are reported for a path
+var diffKinds = []string{"removed", "added", "cardinality", "compositor", "renamed", "type", "facet", "enum"}
+
+// an element or attribute of a message, at its path
+type diffNode struct {
+	typeName  string
+	minOccurs int
+	maxOccurs int
+	attr      bool
+}
+
+// one version of a message
+type diffMessage struct {
+	file  string
+	ctxt  *context
+	nodes map[string]diffNode
+	sigs  map[string]string // the structure of each type, by name
+}
+
+// a change
+type diffChange struct {
+	path   string
+	kind   string
+	old    string
+	new    string
+	detail string // the facet, for a facet change
+	// the values added and removed, for an enumeration change
+	added   []string
+	removed []string
+}
+
+// entry point for the diff command
+func diffMain(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	formatPtr := flags.String("format", diffText, "output format: text, json or markdown")
+	maskPtr := flags.String("mask", "", "mask file: only compare its paths")
+	outPtr := flags.String("out", "", "output file (default standard output)")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Printf(`Usage: %s diff [-format text|json|markdown] [-mask maskfile] [-out file] oldxsd newxsd
+compares two versions of a message by path
+`, filepath.Base(os.Args[0]))
+		os.Exit(1)
+	}
+	if *formatPtr != diffText && *formatPtr != diffJson && *formatPtr != diffMarkdown {
+		fmt.Printf("Invalid -format %s: must be %s, %s or %s\n", *formatPtr, diffText, diffJson, diffMarkdown)
+		os.Exit(1)
+	}
+	var mask []string
+	if *maskPtr != "" {
+		mask = readDiffMask(*maskPtr)
+	}
+
+	old := readDiffMessage(flags.Arg(0), mask)
+	new := readDiffMessage(flags.Arg(1), mask)
+	changes := diffMessages(old, new)
+
+	out := os.Stdout
+	if *outPtr != "" {
+		f, err := os.Create(*outPtr)
+		if err != nil {
+			fmt.Printf("File %v open err %v", *outPtr, err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	switch *formatPtr {
+	case diffJson:
+		writeDiffJson(w, old, new, changes)
+	case diffMarkdown:
+		writeDiffMarkdown(w, old, new, changes)
+	default:
+		writeDiffText(w, changes)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// read the paths of a mask file
+func readDiffMask(fname string) []string {
+	f, err := os.Open(fname)
+	if err != nil {
+		fmt.Printf("File %v open err %v", fname, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0]); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if scanner.Err() != nil {
+		fmt.Printf("File %v scan err %v", fname, scanner.Err())
+		os.Exit(2)
+	}
+	return paths
+}
+
+// is a path compared? with a mask, the paths in it are, with the
+// elements above and below them
+func inDiffMask(path string, mask []string) bool {
+	if mask == nil {
+		return true
+	}
+	for _, m := range mask {
+		if strings.HasPrefix(m+"/", path+"/") || strings.HasPrefix(path, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parse an XSD, and find each element and attribute by path
+func readDiffMessage(fname string, mask []string) *diffMessage {
+	f, err := os.Open(fname)
+	if err != nil {
+		fmt.Printf("File %v open err %v", fname, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+	base := newContext()
+	ctxt := newMessageContext(&base)
+	parseXml(f, &ctxt)
+	if ctxt.root == nil || ctxt.complexTypes[ctxt.root.etype] == nil {
+		fmt.Printf("File %v has no root element\n", fname)
+		os.Exit(2)
+	}
+	msg := &diffMessage{
+		file:  filepath.Base(fname),
+		ctxt:  &ctxt,
+		nodes: make(map[string]diffNode),
+		sigs:  make(map[string]string),
+	}
+	msg.walk(ctxt.root.etype, "", mask, map[string]bool{})
+	return msg
+}
+
+// find the elements and attributes of a complex type
+// seen marks the types on the way down, so a recursive type stops
+func (m *diffMessage) walk(typeName string, path string, mask []string, seen map[string]bool) {
+	cmplx, ok := m.ctxt.complexTypes[typeName]
+	if !ok || seen[typeName] {
+		return
+	}
+	seen[typeName] = true
+	defer delete(seen, typeName)
+	for _, attr := range cmplx.attrs {
+		m.add(path+"/@"+attr.name, attr, mask)
+	}
+	for _, el := range cmplx.elems {
+		elPath := path + "/" + el.name
+		if !inDiffMask(elPath, mask) {
+			continue
+		}
+		m.nodes[elPath] = diffNode{
+			typeName:  el.etype,
+			minOccurs: occurs(el.minOccurs),
+			maxOccurs: occurs(el.maxOccurs),
+		}
+		if simple, ok := m.ctxt.simpleTypes[el.etype]; ok {
+			for _, attr := range simple.attrs {
+				m.add(elPath+"/@"+attr.name, attr, mask)
+			}
+		}
+		m.walk(el.etype, elPath, mask, seen)
+	}
+}
+
+// add an attribute
+func (m *diffMessage) add(path string, attr attribute, mask []string) {
+	if !inDiffMask(path, mask) {
+		return
+	}
+	min := 0
+	if attr.required {
+		min = 1
+	}
+	m.nodes[path] = diffNode{typeName: attr.atype, minOccurs: min, maxOccurs: 1, attr: true}
+}
+
+// minOccurs and maxOccurs are 1 if not given
+func occurs(n int) int {
+	if n < 0 {
+		return 1
+	}
+	return n
+}
+
+// cardinality, e.g. 0..1 or 1..*
+func cardinality(node diffNode) string {
+	max := strconv.Itoa(node.maxOccurs)
+	if node.maxOccurs >= 9999999 {
+		max = "*"
+	}
+	return fmt.Sprintf("%d..%s", node.minOccurs, max)
+}
+
+// the structure of a type, without the names of the types in it, so
+// a type renamed with the same structure has the same signature
+func (m *diffMessage) signature(typeName string, seen map[string]bool) string {
+	if sig, ok := m.sigs[typeName]; ok {
+		return sig
+	}
+	if seen[typeName] {
+		return "recursive"
+	}
+	seen[typeName] = true
+	defer delete(seen, typeName)
+	var sig string
+	if simple, ok := m.ctxt.simpleTypes[typeName]; ok {
+		sig = fmt.Sprintf("simple %s %v %q", localName(simple.base), diffFacets(simple), simple.enum)
+		for _, attr := range simple.attrs {
+			sig += fmt.Sprintf(" @%s %v %s", attr.name, attr.required, m.signature(attr.atype, seen))
+		}
+	} else if cmplx, ok := m.ctxt.complexTypes[typeName]; ok {
+		sig = cmplx.etype + " ("
+		for _, attr := range cmplx.attrs {
+			sig += fmt.Sprintf(" @%s %v %s", attr.name, attr.required, m.signature(attr.atype, seen))
+		}
+		for _, el := range cmplx.elems {
+			sig += fmt.Sprintf(" %s %d %d %s", el.name, occurs(el.minOccurs), occurs(el.maxOccurs), m.signature(el.etype, seen))
+		}
+		sig += " )"
+	} else {
+		sig = "builtin " + localName(typeName)
+	}
+	m.sigs[typeName] = sig
+	return sig
+}
+
+// the facets of a simple type, by name, other than the enumeration
+func diffFacets(simple *simpleType) map[string]string {
+	facets := make(map[string]string)
+	add := func(name string, n int) {
+		if n > -1 {
+			facets[name] = strconv.Itoa(n)
+		}
+	}
+	add("length", simple.length)
+	add("minLength", simple.minLength)
+	add("maxLength", simple.maxLength)
+	add("totalDigits", simple.totalDigits)
+	add("fractionDigits", simple.fractionDigits)
+	add("minInclusive", simple.minInclusive)
+	add("minExclusive", simple.minExclusive)
+	add("maxInclusive", simple.maxInclusive)
+	add("maxExclusive", simple.maxExclusive)
+	if simple.pattern != "" {
+		facets["pattern"] = simple.pattern
+	}
+	if simple.whiteSpace != "" {
+		facets["whiteSpace"] = simple.whiteSpace
+	}
+	return facets
+}
+
+// compare two versions of a message
+// an element added or removed is reported, but not those inside it
+func diffMessages(old *diffMessage, new *diffMessage) []diffChange {
+	changes := make([]diffChange, 0)
+	inside := func(path string, nodes map[string]diffNode, other map[string]diffNode) bool {
+		for p := parentPath(path); p != ""; p = parentPath(p) {
+			if _, ok := nodes[p]; ok {
+				if _, there := other[p]; !there {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for path, o := range old.nodes {
+		n, ok := new.nodes[path]
+		if !ok {
+			if !inside(path, old.nodes, new.nodes) {
+				changes = append(changes, diffChange{path: path, kind: "removed", old: describeNode(o)})
+			}
+			continue
+		}
+		changes = append(changes, diffNodes(path, o, n, old, new)...)
+	}
+	for path, n := range new.nodes {
+		if _, ok := old.nodes[path]; !ok && !inside(path, new.nodes, old.nodes) {
+			changes = append(changes, diffChange{path: path, kind: "added", new: describeNode(n)})
+		}
+	}
+	order := make(map[string]int)
+	for i, k := range diffKinds {
+		order[k] = i
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].path != changes[j].path {
+			return changes[i].path < changes[j].path
+		}
+		if changes[i].kind != changes[j].kind {
+			return order[changes[i].kind] < order[changes[j].kind]
+		}
+		return changes[i].detail < changes[j].detail
+	})
+	return changes
+}
+
+// the path above, e.g. /A for /A/B, or "" at the top
+func parentPath(path string) string {
+	idx := strings.LastIndex(path, "/")
+	if idx < 1 {
+		return ""
+	}
+	return path[:idx]
+}
+
+// an element or attribute, e.g. Max35Text 0..1
+func describeNode(node diffNode) string {
+	return localName(node.typeName) + " " + cardinality(node)
+}
+
+// compare an element or attribute in the two versions
+func diffNodes(path string, o diffNode, n diffNode, old *diffMessage, new *diffMessage) []diffChange {
+	changes := make([]diffChange, 0)
+	if cardinality(o) != cardinality(n) {
+		changes = append(changes, diffChange{path: path, kind: "cardinality", old: cardinality(o), new: cardinality(n)})
+	}
+	if o.typeName != n.typeName {
+		kind := "type"
+		if old.signature(o.typeName, map[string]bool{}) == new.signature(n.typeName, map[string]bool{}) {
+			kind = "renamed"
+		}
+		changes = append(changes, diffChange{path: path, kind: kind, old: o.typeName, new: n.typeName})
+	}
+	oc, ocOk := old.ctxt.complexTypes[o.typeName]
+	nc, ncOk := new.ctxt.complexTypes[n.typeName]
+	if ocOk && ncOk && oc.etype != nc.etype {
+		changes = append(changes, diffChange{path: path, kind: "compositor", old: oc.etype, new: nc.etype})
+	}
+
+	of, nf := make(map[string]string), make(map[string]string)
+	var oe, ne []string
+	if s, ok := old.ctxt.simpleTypes[o.typeName]; ok {
+		of = diffFacets(s)
+		of["base"] = localName(s.base)
+		oe = s.enum
+	}
+	if s, ok := new.ctxt.simpleTypes[n.typeName]; ok {
+		nf = diffFacets(s)
+		nf["base"] = localName(s.base)
+		ne = s.enum
+	}
+	names := make(map[string]bool)
+	for k := range of {
+		names[k] = true
+	}
+	for k := range nf {
+		names[k] = true
+	}
+	for k := range names {
+		if of[k] == nf[k] {
+			continue
+		}
+		changes = append(changes, diffChange{path: path, kind: "facet", old: of[k], new: nf[k], detail: k})
+	}
+	return append(changes, diffEnum(path, oe, ne)...)
+}
+
+// the enumeration values added and removed
+func diffEnum(path string, old []string, new []string) []diffChange {
+	set := func(values []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, v := range values {
+			m[v] = true
+		}
+		return m
+	}
+	o, n := set(old), set(new)
+	removed, added := make([]string, 0), make([]string, 0)
+	for v := range o {
+		if !n[v] {
+			removed = append(removed, v)
+		}
+	}
+	for v := range n {
+		if !o[v] {
+			added = append(added, v)
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return nil // only the order changed
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return []diffChange{{path: path, kind: "enum", added: added, removed: removed}}
+}
+
+// describe a change in words, e.g. maxLength 35 -> 70
+func (c diffChange) text() string {
+	switch c.kind {
+	case "added":
+		return c.new
+	case "removed":
+		return c.old
+	case "enum":
+		parts := make([]string, 0, 2)
+		if len(c.added) > 0 {
+			parts = append(parts, "added "+quoteValues(c.added))
+		}
+		if len(c.removed) > 0 {
+			parts = append(parts, "removed "+quoteValues(c.removed))
+		}
+		return strings.Join(parts, "; ")
+	case "facet":
+		return fmt.Sprintf("%s %s -> %s", c.detail, orNone(c.old), orNone(c.new))
+	}
+	return c.old + " -> " + c.new
+}
+
+// enumeration values, quoted as they may hold spaces, e.g. "A", "B"
+func quoteValues(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// a facet value, or none if it wasn't given
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// write the changes as text, one per line
+func writeDiffText(w io.Writer, changes []diffChange) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%-11s %s: %s\n", c.kind, c.path, c.text())
+	}
+	fmt.Fprintf(w, "%d changes\n", len(changes))
+}
+
+// write the changes as JSON
+func writeDiffJson(w io.Writer, old *diffMessage, new *diffMessage, changes []diffChange) {
+	doc := newDocMap()
+	doc.set("old", old.file)
+	doc.set("new", new.file)
+	list := make(docList, 0, len(changes))
+	for _, c := range changes {
+		m := newDocMap().set("path", c.path).set("kind", c.kind)
+		if c.detail != "" && c.kind == "facet" {
+			m.set("facet", c.detail)
+		}
+		switch c.kind {
+		case "enum":
+			m.set("added", flowStrings(c.added))
+			m.set("removed", flowStrings(c.removed))
+		default:
+			if c.old != "" {
+				m.set("old", c.old)
+			}
+			if c.new != "" {
+				m.set("new", c.new)
+			}
+		}
+		list = append(list, m)
+	}
+	doc.set("changes", list)
+	if err := encodeDoc(w, doc, formatJson); err != nil {
+		fmt.Printf("Write failed: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// write the changes as a Markdown table
+func writeDiffMarkdown(w io.Writer, old *diffMessage, new *diffMessage, changes []diffChange) {
+	cell := func(s string) string {
+		return strings.Replace(s, "|", "\\|", -1)
+	}
+	fmt.Fprintf(w, "# %s to %s\n\n", diffTitle(old), diffTitle(new))
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "No changes.\n")
+		return
+	}
+	counts := make(map[string]int)
+	for _, c := range changes {
+		counts[c.kind]++
+	}
+	summary := make([]string, 0, len(diffKinds))
+	for _, k := range diffKinds {
+		if counts[k] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[k], k))
+		}
+	}
+	fmt.Fprintf(w, "%d changes: %s\n\n", len(changes), strings.Join(summary, ", "))
+	fmt.Fprintf(w, "Change|Path|Detail\n------|----|------\n")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s|`%s`|%s\n", c.kind, c.path, cell(c.text()))
+	}
+}
+
+// the message, e.g. pacs.008.001.08, or else the file
+func diffTitle(m *diffMessage) string {
+	if id := parseIsoNamespace(m.ctxt.namespace); id != nil {
+		return id.String()
+	}
+	return m.file
+}
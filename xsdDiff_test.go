@@ -0,0 +1,156 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// a message with a group header, whose types are given
+const diffXsd = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:test">
+  <xs:element name="Document" type="Document"/>
+  <xs:complexType name="Document"><xs:sequence><xs:element name="Msg" type="Message"/></xs:sequence></xs:complexType>
+  <xs:complexType name="Message"><xs:sequence>
+    <xs:element name="GrpHdr" type="GroupHeader"/>
+    <xs:element name="Rmt" type="Max140Text" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max140Text"><xs:restriction base="xs:string"><xs:maxLength value="140"/></xs:restriction></xs:simpleType>
+  %s
+</xs:schema>
+`
+
+// read a version of the message
+func readDiffXsd(t *testing.T, name string, types string, mask []string) *diffMessage {
+	fname := filepath.Join(t.TempDir(), name)
+	text := []byte(fmt.Sprintf(diffXsd, types))
+	if err := os.WriteFile(fname, text, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return readDiffMessage(fname, mask)
+}
+
+// the group header of the first version
+const diffHeader = `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max35Text"/>
+    <xs:element name="Cd" type="Code" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max35Text"><xs:restriction base="xs:string"><xs:maxLength value="35"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="CRED"/><xs:enumeration value="DEBT"/><xs:enumeration value="SHAR"/>
+  </xs:restriction></xs:simpleType>`
+
+func TestDiffMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []diffChange
+	}{
+		{"same", diffHeader, []diffChange{}},
+		// the type has a new name, but the same structure
+		{"renamed", `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max35Text2"/>
+    <xs:element name="Cd" type="Code" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max35Text2"><xs:restriction base="xs:string"><xs:maxLength value="35"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="CRED"/><xs:enumeration value="DEBT"/><xs:enumeration value="SHAR"/>
+  </xs:restriction></xs:simpleType>`, []diffChange{
+			{path: "/Msg/GrpHdr/MsgId", kind: "renamed", old: "Max35Text", new: "Max35Text2"},
+		}},
+		// a different type: its facets change too
+		{"type", `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max70Text"/>
+    <xs:element name="Cd" type="Code" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max70Text"><xs:restriction base="xs:string"><xs:maxLength value="70"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="CRED"/><xs:enumeration value="DEBT"/><xs:enumeration value="SHAR"/>
+  </xs:restriction></xs:simpleType>`, []diffChange{
+			{path: "/Msg/GrpHdr/MsgId", kind: "type", old: "Max35Text", new: "Max70Text"},
+			{path: "/Msg/GrpHdr/MsgId", kind: "facet", old: "35", new: "70", detail: "maxLength"},
+		}},
+		// only the order of the values changes
+		{"enum order", `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max35Text"/>
+    <xs:element name="Cd" type="Code" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max35Text"><xs:restriction base="xs:string"><xs:maxLength value="35"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="SHAR"/><xs:enumeration value="CRED"/><xs:enumeration value="DEBT"/>
+  </xs:restriction></xs:simpleType>`, []diffChange{}},
+		{"enum values", `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max35Text"/>
+    <xs:element name="Cd" type="Code" minOccurs="0"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max35Text"><xs:restriction base="xs:string"><xs:maxLength value="35"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="CRED"/><xs:enumeration value="SLEV"/><xs:enumeration value="SHAR"/>
+  </xs:restriction></xs:simpleType>`, []diffChange{
+			{path: "/Msg/GrpHdr/Cd", kind: "enum", added: []string{"SLEV"}, removed: []string{"DEBT"}},
+		}},
+	}
+	old := readDiffXsd(t, "old.xsd", diffHeader, nil)
+	for _, tt := range tests {
+		new := readDiffXsd(t, "new.xsd", tt.header, nil)
+		if got := diffMessages(old, new); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: diffMessages = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// with a mask, only the paths in it (and above and below them) are compared
+func TestDiffMessagesMask(t *testing.T) {
+	header := `
+  <xs:complexType name="GroupHeader"><xs:sequence>
+    <xs:element name="MsgId" type="Max35Text" minOccurs="0"/>
+    <xs:element name="Cd" type="Code"/>
+  </xs:sequence></xs:complexType>
+  <xs:simpleType name="Max35Text"><xs:restriction base="xs:string"><xs:maxLength value="35"/></xs:restriction></xs:simpleType>
+  <xs:simpleType name="Code"><xs:restriction base="xs:string">
+    <xs:enumeration value="CRED"/><xs:enumeration value="DEBT"/><xs:enumeration value="SHAR"/>
+  </xs:restriction></xs:simpleType>`
+	tests := []struct {
+		mask []string
+		want []diffChange
+	}{
+		{nil, []diffChange{
+			{path: "/Msg/GrpHdr/Cd", kind: "cardinality", old: "0..1", new: "1..1"},
+			{path: "/Msg/GrpHdr/MsgId", kind: "cardinality", old: "1..1", new: "0..1"},
+		}},
+		{[]string{"/Msg/GrpHdr/MsgId"}, []diffChange{
+			{path: "/Msg/GrpHdr/MsgId", kind: "cardinality", old: "1..1", new: "0..1"},
+		}},
+		{[]string{"/Msg/Rmt"}, []diffChange{}},
+	}
+	for _, tt := range tests {
+		old := readDiffXsd(t, "old.xsd", diffHeader, tt.mask)
+		new := readDiffXsd(t, "new.xsd", header, tt.mask)
+		if got := diffMessages(old, new); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mask %v: diffMessages = %+v, want %+v", tt.mask, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,586 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+// xsdPattern
+// translate the XSD regex dialect into ECMA-262 (for OAS) or Go (for reggen)
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// target regex dialects
+type regexDialect int
+
+const (
+	ecmaRegex regexDialect = iota // ECMA-262, used by OAS / JSON schema
+	goRegex                       // Go RE2, used by reggen for sample data
+)
+
+const maxBmp = 0xFFFF // ECMA patterns without the u flag only address the BMP
+
+// the surrogates, which aren't characters, so no class includes them
+var surrogates = runeRange{0xD800, 0xDFFF}
+
+// an inclusive range of characters
+type runeRange struct {
+	lo, hi rune
+}
+
+// a character class being translated
+// for Go, escapes like \d are kept as tokens (\p{Nd}) unless
+// they have to be turned into ranges for subtraction
+type charClass struct {
+	negated bool
+	ranges  []runeRange
+	tokens  []rune
+}
+
+// state of one translation
+type patternTranslator struct {
+	src     []rune
+	pos     int
+	dialect regexDialect
+	diags   []string
+}
+
+// XML NameStartChar, used by \i
+var nameStartRanges = []runeRange{
+	{':', ':'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}, {0xC0, 0xD6}, {0xD8, 0xF6},
+	{0xF8, 0x2FF}, {0x370, 0x37D}, {0x37F, 0x1FFF}, {0x200C, 0x200D},
+	{0x2070, 0x218F}, {0x2C00, 0x2FEF}, {0x3001, 0xD7FF}, {0xF900, 0xFDCF},
+	{0xFDF0, 0xFFFD},
+}
+
+// XML NameChar, used by \c
+var nameRanges = append([]runeRange{
+	{'-', '.'}, {'0', '9'}, {0xB7, 0xB7}, {0x300, 0x36F}, {0x203F, 0x2040},
+}, nameStartRanges...)
+
+// XSD \s: space, tab, newline and return (ECMA and Go \s match more)
+var spaceRanges = []runeRange{{'\t', '\n'}, {'\r', '\r'}, {' ', ' '}}
+
+// the Go classes for the XSD multi-character escapes that Go can write
+// as Unicode classes; ECMA-262 has no \p without the u flag, so there
+// they are ranges
+var goEscapes = map[rune]string{
+	'd': "\\p{Nd}",
+	'D': "\\P{Nd}",
+	'W': "\\p{P}\\p{Z}\\p{C}",
+}
+
+// Unicode blocks that can be named in \p{IsXxx}
+var unicodeBlocks = map[string]runeRange{
+	"BasicLatin":                         {0x0000, 0x007F},
+	"Latin-1Supplement":                  {0x0080, 0x00FF},
+	"LatinExtended-A":                    {0x0100, 0x017F},
+	"LatinExtended-B":                    {0x0180, 0x024F},
+	"IPAExtensions":                      {0x0250, 0x02AF},
+	"SpacingModifierLetters":             {0x02B0, 0x02FF},
+	"CombiningDiacriticalMarks":          {0x0300, 0x036F},
+	"Greek":                              {0x0370, 0x03FF},
+	"Cyrillic":                           {0x0400, 0x04FF},
+	"Armenian":                           {0x0530, 0x058F},
+	"Hebrew":                             {0x0590, 0x05FF},
+	"Arabic":                             {0x0600, 0x06FF},
+	"Devanagari":                         {0x0900, 0x097F},
+	"Thai":                               {0x0E00, 0x0E7F},
+	"LatinExtendedAdditional":            {0x1E00, 0x1EFF},
+	"GreekExtended":                      {0x1F00, 0x1FFF},
+	"GeneralPunctuation":                 {0x2000, 0x206F},
+	"SuperscriptsandSubscripts":          {0x2070, 0x209F},
+	"CurrencySymbols":                    {0x20A0, 0x20CF},
+	"LetterlikeSymbols":                  {0x2100, 0x214F},
+	"NumberForms":                        {0x2150, 0x218F},
+	"Arrows":                             {0x2190, 0x21FF},
+	"MathematicalOperators":              {0x2200, 0x22FF},
+	"CJKSymbolsandPunctuation":           {0x3000, 0x303F},
+	"Hiragana":                           {0x3040, 0x309F},
+	"Katakana":                           {0x30A0, 0x30FF},
+	"CJKUnifiedIdeographs":               {0x4E00, 0x9FFF},
+	"HangulSyllables":                    {0xAC00, 0xD7AF},
+	"PrivateUse":                         {0xE000, 0xF8FF},
+	"HalfwidthandFullwidthForms":         {0xFF00, 0xFFEF},
+	"Specials":                           {0xFFF0, 0xFFFF},
+	"AlphabeticPresentationForms":        {0xFB00, 0xFB4F},
+	"ArabicPresentationForms-A":          {0xFB50, 0xFDFF},
+	"ArabicPresentationForms-B":          {0xFE70, 0xFEFF},
+	"CJKCompatibilityIdeographs":         {0xF900, 0xFAFF},
+	"EnclosedAlphanumerics":              {0x2460, 0x24FF},
+	"BoxDrawing":                         {0x2500, 0x257F},
+	"GeometricShapes":                    {0x25A0, 0x25FF},
+	"MiscellaneousSymbols":               {0x2600, 0x26FF},
+	"MiscellaneousTechnical":             {0x2300, 0x23FF},
+	"ControlPictures":                    {0x2400, 0x243F},
+	"CombiningMarksforSymbols":           {0x20D0, 0x20FF},
+	"CombiningHalfMarks":                 {0xFE20, 0xFE2F},
+	"SmallFormVariants":                  {0xFE50, 0xFE6F},
+	"HangulJamo":                         {0x1100, 0x11FF},
+	"Georgian":                           {0x10A0, 0x10FF},
+	"Bengali":                            {0x0980, 0x09FF},
+	"Tamil":                              {0x0B80, 0x0BFF},
+	"EnclosedCJKLettersandMonths":        {0x3200, 0x32FF},
+	"CJKCompatibility":                   {0x3300, 0x33FF},
+	"CJKCompatibilityForms":              {0xFE30, 0xFE4F},
+	"OpticalCharacterRecognition":        {0x2440, 0x245F},
+	"Dingbats":                           {0x2700, 0x27BF},
+	"BraillePatterns":                    {0x2800, 0x28FF},
+	"IdeographicDescriptionChars":        {0x2FF0, 0x2FFF},
+	"Bopomofo":                           {0x3100, 0x312F},
+	"KangxiRadicals":                     {0x2F00, 0x2FDF},
+	"CJKRadicalsSupplement":              {0x2E80, 0x2EFF},
+	"YiSyllables":                        {0xA000, 0xA48F},
+	"YiRadicals":                         {0xA490, 0xA4CF},
+	"Syriac":                             {0x0700, 0x074F},
+	"Thaana":                             {0x0780, 0x07BF},
+	"Ethiopic":                           {0x1200, 0x137F},
+	"Cherokee":                           {0x13A0, 0x13FF},
+	"UnifiedCanadianAboriginalSyllabics": {0x1400, 0x167F},
+	"Ogham":                              {0x1680, 0x169F},
+	"Runic":                              {0x16A0, 0x16FF},
+	"Khmer":                              {0x1780, 0x17FF},
+	"Mongolian":                          {0x1800, 0x18AF},
+}
+
+// translate an XSD pattern into the target dialect
+// XSD patterns are implicitly anchored, so the result is wrapped in ^...$
+// constructs that can't be translated are reported as diagnostics
+func translatePattern(xsd string, dialect regexDialect) (string, []string) {
+	t := &patternTranslator{src: []rune(xsd), dialect: dialect}
+	body, alternation := t.regExp()
+	if t.more() {
+		t.diag("unbalanced ')' at position %d", t.pos)
+	}
+	if alternation {
+		body = "(?:" + body + ")"
+	}
+	return "^" + body + "$", t.diags
+}
+
+// report something that couldn't be translated exactly
+func (t *patternTranslator) diag(format string, v ...interface{}) {
+	t.diags = append(t.diags, fmt.Sprintf(format, v...))
+}
+
+func (t *patternTranslator) more() bool {
+	return t.pos < len(t.src)
+}
+
+func (t *patternTranslator) peek() rune {
+	return t.src[t.pos]
+}
+
+func (t *patternTranslator) next() rune {
+	r := t.src[t.pos]
+	t.pos++
+	return r
+}
+
+// translate a sequence of branches, stopping at an unmatched ')'
+// also returns whether there was a top-level alternation
+func (t *patternTranslator) regExp() (string, bool) {
+	var sb strings.Builder
+	alternation := false
+	for t.more() {
+		r := t.peek()
+		switch r {
+		case ')':
+			return sb.String(), alternation
+		case '(':
+			t.next()
+			inner, _ := t.regExp()
+			if t.more() {
+				t.next() // the ')'
+			} else {
+				t.diag("unbalanced '('")
+			}
+			sb.WriteString("(" + inner + ")")
+		case '|':
+			t.next()
+			alternation = true
+			sb.WriteRune(r)
+		case '[':
+			t.next()
+			sb.WriteString(t.emitClass(t.charClassExpr()))
+		case '\\':
+			t.next()
+			sb.WriteString(t.escape())
+		case '^', '$':
+			// not anchors in XSD, just characters
+			t.next()
+			sb.WriteString("\\" + string(r))
+		default:
+			t.next()
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String(), alternation
+}
+
+// translate an escape outside a character class
+func (t *patternTranslator) escape() string {
+	if !t.more() {
+		t.diag("trailing '\\'")
+		return "\\\\"
+	}
+	r := t.next()
+	switch r {
+	case 'n', 'r', 't', '\\', '|', '.', '?', '*', '+', '(', ')', '{', '}', '-', '[', ']', '^', '$':
+		return "\\" + string(r)
+	case 'w':
+		if t.dialect == goRegex {
+			return "[^" + goEscapes['W'] + "]"
+		}
+	}
+	cls := &charClass{}
+	if !t.classEscape(r, cls) {
+		return ""
+	}
+	return t.emitClass(cls)
+}
+
+// add the ranges of a multi-character or category escape to a class
+// returns false if the escape was not recognised
+func (t *patternTranslator) classEscape(r rune, cls *charClass) bool {
+	var ranges []runeRange
+	negate := false
+	switch r {
+	case 'i', 'I':
+		ranges, negate = nameStartRanges, r == 'I'
+	case 'c', 'C':
+		ranges, negate = nameRanges, r == 'C'
+	case 'p', 'P':
+		ranges, negate = t.property(), r == 'P'
+		if ranges == nil {
+			return false
+		}
+	case 'd', 'D', 's', 'S', 'w', 'W':
+		if _, ok := goEscapes[r]; ok && t.dialect == goRegex {
+			cls.tokens = append(cls.tokens, r)
+			return true
+		}
+		ranges = escapeRanges(r)
+	default:
+		t.diag("unknown escape '\\%c'", r)
+		return false
+	}
+	if negate {
+		ranges = complement(ranges)
+	}
+	cls.ranges = append(cls.ranges, ranges...)
+	return true
+}
+
+// parse {Name} after \p or \P and return its ranges
+func (t *patternTranslator) property() []runeRange {
+	if !t.more() || t.peek() != '{' {
+		t.diag("'\\p' without '{'")
+		return nil
+	}
+	t.next()
+	start := t.pos
+	for t.more() && t.peek() != '}' {
+		t.next()
+	}
+	name := string(t.src[start:t.pos])
+	if !t.more() {
+		t.diag("unterminated '\\p{%s'", name)
+		return nil
+	}
+	t.next()
+
+	if strings.HasPrefix(name, "Is") {
+		block, ok := unicodeBlocks[name[2:]]
+		if !ok {
+			t.diag("unknown Unicode block '%s'", name)
+			return nil
+		}
+		return []runeRange{block}
+	}
+	table, ok := unicode.Categories[name]
+	if !ok {
+		t.diag("unknown Unicode category '%s'", name)
+		return nil
+	}
+	if len(table.R32) > 0 {
+		t.diag("category '%s' restricted to the Basic Multilingual Plane", name)
+	}
+	return categoryRanges(table)
+}
+
+// the ranges of a Unicode category in the Basic Multilingual Plane
+func categoryRanges(table *unicode.RangeTable) []runeRange {
+	ranges := make([]runeRange, 0)
+	for _, r16 := range table.R16 {
+		for lo := rune(r16.Lo); lo <= rune(r16.Hi); lo += rune(r16.Stride) {
+			hi := lo
+			if r16.Stride == 1 {
+				hi = rune(r16.Hi)
+			}
+			ranges = append(ranges, runeRange{lo, hi})
+			if r16.Stride == 1 {
+				break
+			}
+		}
+	}
+	return ranges
+}
+
+// the ranges of a multi-character escape, with the XSD meaning:
+// \d is any Unicode digit (Nd), and \w anything but punctuation,
+// separators and other characters (P, Z and C)
+func escapeRanges(r rune) []runeRange {
+	var ranges []runeRange
+	switch r {
+	case 'd', 'D':
+		ranges = categoryRanges(unicode.Nd)
+	case 's', 'S':
+		ranges = spaceRanges
+	case 'w', 'W':
+		ranges = append(append(categoryRanges(unicode.P), categoryRanges(unicode.Z)...), categoryRanges(unicode.C)...)
+	}
+	if r == 'D' || r == 'S' || r == 'w' {
+		return complement(ranges)
+	}
+	return normalise(ranges)
+}
+
+// parse a character class expression, after the '['
+// handles negation, ranges, escapes and subtraction [a-z-[aeiou]]
+func (t *patternTranslator) charClassExpr() *charClass {
+	cls := &charClass{}
+	if t.more() && t.peek() == '^' {
+		t.next()
+		cls.negated = true
+	}
+	first := true
+	for t.more() {
+		r := t.next()
+		if r == ']' && !first {
+			return cls
+		}
+		first = false
+		switch {
+		case r == '-' && t.more() && t.peek() == '[':
+			t.next()
+			sub := t.charClassExpr()
+			if t.more() && t.peek() == ']' {
+				t.next()
+			} else {
+				t.diag("subtraction must end the character class")
+			}
+			return t.subtract(cls, sub)
+		case r == '\\':
+			lo, ok := t.classCharInto(cls)
+			if !ok {
+				continue
+			}
+			t.addRange(cls, lo)
+		default:
+			t.addRange(cls, r)
+		}
+	}
+	t.diag("unterminated character class")
+	return cls
+}
+
+// parse an escape inside a class: returns a single char, or adds
+// multi-character escapes directly to the class and returns false
+func (t *patternTranslator) classCharInto(cls *charClass) (rune, bool) {
+	if !t.more() {
+		t.diag("trailing '\\'")
+		return 0, false
+	}
+	r := t.next()
+	switch r {
+	case 'n':
+		return '\n', true
+	case 'r':
+		return '\r', true
+	case 't':
+		return '\t', true
+	case '\\', '|', '.', '?', '*', '+', '(', ')', '{', '}', '-', '[', ']', '^', '$':
+		return r, true
+	}
+	t.classEscape(r, cls)
+	return 0, false
+}
+
+// add a single character, or a range if followed by -x
+func (t *patternTranslator) addRange(cls *charClass, lo rune) {
+	hi := lo
+	if t.pos+1 < len(t.src) && t.src[t.pos] == '-' && t.src[t.pos+1] != '[' && t.src[t.pos+1] != ']' {
+		t.next()
+		r := t.next()
+		if r == '\\' {
+			tmp := &charClass{}
+			c, ok := t.classCharInto(tmp)
+			if !ok {
+				t.diag("invalid range end")
+				return
+			}
+			r = c
+		}
+		hi = r
+	}
+	if hi < lo {
+		t.diag("invalid range %c-%c", lo, hi)
+		return
+	}
+	cls.ranges = append(cls.ranges, runeRange{lo, hi})
+}
+
+// subtract one class from another
+// tokens like \d have to become ranges first
+func (t *patternTranslator) subtract(base, sub *charClass) *charClass {
+	return &charClass{ranges: rangeDiff(classRanges(base), classRanges(sub))}
+}
+
+// all the characters matched by a class as ranges
+func classRanges(cls *charClass) []runeRange {
+	ranges := append([]runeRange{}, cls.ranges...)
+	for _, tok := range cls.tokens {
+		ranges = append(ranges, escapeRanges(tok)...)
+	}
+	if cls.negated {
+		return complement(ranges)
+	}
+	return normalise(ranges)
+}
+
+// write a class in the target dialect
+func (t *patternTranslator) emitClass(cls *charClass) string {
+	ranges := rangeDiff(cls.ranges, nil) // without the surrogates
+	negated := cls.negated
+	if len(ranges) == 0 && len(cls.tokens) == 0 {
+		// [] and [^] aren't valid, so the class is every character,
+		// negated if it's to match none
+		if !negated {
+			t.diag("empty character class")
+		}
+		ranges, negated = []runeRange{{0, maxBmp}}, !negated
+		if t.dialect == goRegex {
+			ranges[0].hi = unicode.MaxRune
+		}
+	} else if negated && t.dialect == goRegex {
+		// so reggen doesn't generate them; ECMA matches UTF-16 code
+		// units, so there they're the halves of other characters
+		ranges = normalise(append(ranges, surrogates))
+	}
+	var sb strings.Builder
+	sb.WriteString("[")
+	if negated {
+		sb.WriteString("^")
+	}
+	for _, rr := range ranges {
+		sb.WriteString(t.classLiteral(rr.lo))
+		if rr.hi > rr.lo+1 {
+			sb.WriteString("-")
+		}
+		if rr.hi > rr.lo {
+			sb.WriteString(t.classLiteral(rr.hi))
+		}
+	}
+	for _, tok := range cls.tokens {
+		sb.WriteString(goEscapes[tok])
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// write a character inside a class, escaping as necessary
+func (t *patternTranslator) classLiteral(r rune) string {
+	switch {
+	case r == '\\' || r == ']' || r == '[' || r == '^' || r == '-':
+		return "\\" + string(r)
+	case r == '\n':
+		return "\\n"
+	case r == '\r':
+		return "\\r"
+	case r == '\t':
+		return "\\t"
+	case r > ' ' && r < 0x7F:
+		return string(r)
+	case t.dialect == goRegex:
+		return fmt.Sprintf("\\x{%04X}", r)
+	case r > maxBmp:
+		t.diag("character U+%04X outside the Basic Multilingual Plane", r)
+		return fmt.Sprintf("\\u%04X", maxBmp)
+	}
+	return fmt.Sprintf("\\u%04X", r)
+}
+
+// sort and merge overlapping or adjacent ranges
+func normalise(ranges []runeRange) []runeRange {
+	if len(ranges) == 0 {
+		return ranges
+	}
+	sorted := append([]runeRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lo < sorted[j].lo })
+	merged := []runeRange{sorted[0]}
+	for _, rr := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if rr.lo <= last.hi+1 {
+			if rr.hi > last.hi {
+				last.hi = rr.hi
+			}
+		} else {
+			merged = append(merged, rr)
+		}
+	}
+	return merged
+}
+
+// all BMP characters not in the ranges, other than the surrogates
+func complement(ranges []runeRange) []runeRange {
+	result := make([]runeRange, 0)
+	next := rune(0)
+	for _, rr := range normalise(append(append([]runeRange{}, ranges...), surrogates)) {
+		if rr.lo > next {
+			result = append(result, runeRange{next, rr.lo - 1})
+		}
+		next = rr.hi + 1
+	}
+	if next <= maxBmp {
+		result = append(result, runeRange{next, maxBmp})
+	}
+	return result
+}
+
+// characters in a but not in b
+func rangeDiff(a, b []runeRange) []runeRange {
+	bc := complement(b)
+	result := make([]runeRange, 0)
+	for _, ra := range normalise(a) {
+		for _, rb := range bc {
+			lo, hi := ra.lo, ra.hi
+			if rb.lo > lo {
+				lo = rb.lo
+			}
+			if rb.hi < hi {
+				hi = rb.hi
+			}
+			if lo <= hi {
+				result = append(result, runeRange{lo, hi})
+			}
+		}
+	}
+	return result
+}
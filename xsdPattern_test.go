@@ -0,0 +1,174 @@
+// xsd2oas - convert XSD files to OpenAPI Specification
+// Copyright (C) 2019  Tom Hay
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.package main
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// the translations of some patterns, written out
+func TestTranslatePattern(t *testing.T) {
+	tests := []struct {
+		xsd     string
+		dialect regexDialect
+		want    string
+	}{
+		{`[A-Z]{3,3}`, ecmaRegex, `^[A-Z]{3,3}$`},
+		{`[A-Z]{3,3}`, goRegex, `^[A-Z]{3,3}$`},
+		{`CRED|DEBT`, ecmaRegex, `^(?:CRED|DEBT)$`},
+		{`(CRED|DEBT)X`, ecmaRegex, `^(CRED|DEBT)X$`},
+		{`a^b$`, ecmaRegex, `^a\^b\$$`},
+		{`[a-z-[aeiou]]`, ecmaRegex, `^[b-df-hj-np-tv-z]$`},
+		{`[a-z-[^aeiou]]`, ecmaRegex, `^[aeiou]$`},
+		{`\s`, ecmaRegex, `^[\t\n\r\u0020]$`},
+		{`\s`, goRegex, `^[\t\n\r\x{0020}]$`},
+		{`\d{2}`, goRegex, `^[\p{Nd}]{2}$`},
+		{`[^\d]`, goRegex, `^[^\x{D800}-\x{DFFF}\p{Nd}]$`},
+		{`\D`, goRegex, `^[\P{Nd}]$`},
+		{`\w+`, goRegex, `^[^\p{P}\p{Z}\p{C}]+$`},
+		{`\W`, goRegex, `^[\p{P}\p{Z}\p{C}]$`},
+	}
+	for _, tt := range tests {
+		got, diags := translatePattern(tt.xsd, tt.dialect)
+		if got != tt.want {
+			t.Errorf("translatePattern(%q, %v) = %q, want %q", tt.xsd, tt.dialect, got, tt.want)
+		}
+		if len(diags) > 0 {
+			t.Errorf("translatePattern(%q, %v) diagnostics %v", tt.xsd, tt.dialect, diags)
+		}
+	}
+}
+
+// ECMA-262 \uXXXX escapes, which Go writes as \x{XXXX}
+var regEcmaEscape = regexp.MustCompile(`\\u([0-9A-F]{4})`)
+
+// the XSD meaning of the translations, in both dialects
+// the ECMA translations are checked with Go's regexp, once their \u
+// escapes are rewritten, which is the same for the BMP
+func TestTranslatePatternMatches(t *testing.T) {
+	tests := []struct {
+		xsd   string
+		match []string
+		not   []string
+	}{
+		{`[0-9]{2}`, []string{"12"}, []string{"123", "x12", "12x"}},
+		{`\d{2}`, []string{"12", "٣٤", "१२"}, []string{"1", "ab", "1a"}},
+		{`\D+`, []string{"ab", "-"}, []string{"a1", "٣"}},
+		{`\s`, []string{" ", "\t", "\n", "\r"}, []string{"\f", "\u00A0", "a"}},
+		{`\S+`, []string{"a\u00A0b"}, []string{"a b"}},
+		{`\w+`, []string{"abc", "Zürich", "東京", "1€"}, []string{"_", "a-b", "a b", "a.b"}},
+		{`\W`, []string{"_", "-", " ", "."}, []string{"a", "é", "1"}},
+		{`[\w-[a-z]]+`, []string{"ABC", "É1"}, []string{"a", "abc"}},
+		{`[\d-[5-9]]`, []string{"0", "4", "٣"}, []string{"5", "9"}},
+		{`[\s\d]+`, []string{"1 2", "٣\t"}, []string{"a"}},
+		{`\i\c*`, []string{"x:y-1", "_a.b"}, []string{"1x", "-a"}},
+		{`\p{Zs}+`, []string{" \u00A0"}, []string{"\t", "a"}},
+		{`\p{IsBasicLatin}+`, []string{"abc~"}, []string{"é"}},
+		{`[A-Z]{2}|[0-9]{3}`, []string{"GB", "123"}, []string{"GB1", "G123"}},
+	}
+	for _, tt := range tests {
+		for _, dialect := range []regexDialect{ecmaRegex, goRegex} {
+			patt, diags := translatePattern(tt.xsd, dialect)
+			if len(diags) > 0 {
+				t.Errorf("translatePattern(%q, %v) diagnostics %v", tt.xsd, dialect, diags)
+			}
+			if dialect == ecmaRegex {
+				patt = regEcmaEscape.ReplaceAllString(patt, `\x{$1}`)
+			}
+			re, err := regexp.Compile(patt)
+			if err != nil {
+				t.Errorf("translatePattern(%q, %v) = %q, which doesn't compile: %v", tt.xsd, dialect, patt, err)
+				continue
+			}
+			for _, s := range tt.match {
+				if !re.MatchString(s) {
+					t.Errorf("translatePattern(%q, %v) doesn't match %q", tt.xsd, dialect, s)
+				}
+			}
+			for _, s := range tt.not {
+				if re.MatchString(s) {
+					t.Errorf("translatePattern(%q, %v) matches %q", tt.xsd, dialect, s)
+				}
+			}
+		}
+	}
+}
+
+// an empty class is one that matches nothing, as [] isn't valid
+func TestTranslatePatternEmptyClass(t *testing.T) {
+	tests := []struct {
+		dialect regexDialect
+		want    string
+	}{
+		{ecmaRegex, `^[^\u0000-\uFFFF]$`},
+		{goRegex, `^[^\x{0000}-\x{10FFFF}]$`},
+	}
+	for _, tt := range tests {
+		got, diags := translatePattern(`[\d-[\d]]`, tt.dialect)
+		if got != tt.want {
+			t.Errorf("translatePattern(%q, %v) = %q, want %q", `[\d-[\d]]`, tt.dialect, got, tt.want)
+		}
+		if len(diags) != 1 {
+			t.Errorf("translatePattern(%q, %v) diagnostics %v, want one", `[\d-[\d]]`, tt.dialect, diags)
+		}
+	}
+	patt, _ := translatePattern(`a[\d-[\d]]?`, goRegex)
+	re := regexp.MustCompile(patt)
+	if !re.MatchString("a") || re.MatchString("a1") {
+		t.Errorf("%q doesn't match only a", patt)
+	}
+}
+
+// the ranges of a class, e.g. \u00C0-\u00D6
+var regEcmaRange = regexp.MustCompile(`\\u([0-9A-F]{4})(?:-\\u([0-9A-F]{4}))?`)
+
+// the classes don't include the surrogates, which can't be encoded
+func TestTranslatePatternSurrogates(t *testing.T) {
+	for _, xsd := range []string{`\w`, `\W`, `\D`, `[\d-[0-9]]`, `[\c-[a-z]]`, `\P{L}`} {
+		patt, _ := translatePattern(xsd, ecmaRegex)
+		for _, m := range regEcmaRange.FindAllStringSubmatch(patt, -1) {
+			lo, _ := strconv.ParseUint(m[1], 16, 32)
+			hi := lo
+			if m[2] != "" {
+				hi, _ = strconv.ParseUint(m[2], 16, 32)
+			}
+			if lo <= 0xDFFF && hi >= 0xD800 {
+				t.Errorf("translatePattern(%q) includes the surrogates, in %s", xsd, m[0])
+			}
+		}
+	}
+}
+
+// what can't be translated is reported
+func TestTranslatePatternDiagnostics(t *testing.T) {
+	tests := []string{
+		`\q`,
+		`[a-z`,
+		`(ab`,
+		`ab)`,
+		`\p{Xx}`,
+		`\p{IsNoSuchBlock}`,
+		`[z-a]`,
+	}
+	for _, xsd := range tests {
+		if _, diags := translatePattern(xsd, ecmaRegex); len(diags) == 0 {
+			t.Errorf("translatePattern(%q) gave no diagnostic", xsd)
+		}
+	}
+}
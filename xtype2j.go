@@ -78,12 +78,57 @@ var xtype2j = map[string]string{
 	"notation":     "string",
 }
 
-// map XML typenames to JSON
-func mapTypename(name string) (string, bool) {
+// kinds of XSD builtin types, for the code generators
+const (
+	kindString   = "string"
+	kindBoolean  = "boolean"
+	kindInteger  = "integer"
+	kindFloat    = "float"
+	kindDecimal  = "decimal"
+	kindDate     = "date"
+	kindDateTime = "dateTime"
+	kindTime     = "time"
+	kindBinary   = "binary"
+)
+
+// the kind of an XSD builtin type
+// types such as gYear and duration are strings
+func builtinKind(name string) string {
+	name = localName(name)
+	switch name {
+	case "boolean":
+		return kindBoolean
+	case "decimal":
+		return kindDecimal
+	case "float", "double":
+		return kindFloat
+	case "date":
+		return kindDate
+	case "dateTime", "dateTimeStamp":
+		return kindDateTime
+	case "time":
+		return kindTime
+	case "base64Binary":
+		return kindBinary
+	}
+	if jname, _ := mapTypename(name); jname == "number" {
+		return kindInteger
+	}
+	return kindString
+}
+
+// strip any namespace prefix from an XML name
+func localName(name string) string {
 	idx := strings.Index(name, ":")
 	if idx > -1 {
 		name = name[idx+1:]
 	}
+	return name
+}
+
+// map XML typenames to JSON
+func mapTypename(name string) (string, bool) {
+	name = localName(name)
 	jname, mapped := xtype2j[name]
 	if mapped {
 		name = jname